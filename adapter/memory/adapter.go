@@ -0,0 +1,355 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mertenvg/migrate/pkg/checksum"
+)
+
+// Method names an Adapter method failures can be injected into
+type Method string
+
+const (
+	MethodSetup       Method = "Setup"
+	MethodList        Method = "List"
+	MethodBegin       Method = "Begin"
+	MethodUp          Method = "Up"
+	MethodDown        Method = "Down"
+	MethodCommit      Method = "Commit"
+	MethodRollback    Method = "Rollback"
+	MethodRollbackSQL Method = "RollbackSQL"
+	MethodChecksums   Method = "Checksums"
+	MethodSetChecksum Method = "SetChecksum"
+	MethodLock        Method = "Lock"
+	MethodUnlock      Method = "Unlock"
+)
+
+// needsSetup holds the methods using the migration store, which fail until Setup was called
+var needsSetup = map[Method]bool{
+	MethodList:        true,
+	MethodUp:          true,
+	MethodDown:        true,
+	MethodRollbackSQL: true,
+	MethodChecksums:   true,
+	MethodSetChecksum: true,
+}
+
+// ErrNotApplied is returned when taking down, or reading the rollback of, a migration that is not applied
+var ErrNotApplied = errors.New("migration is not applied")
+
+// ErrApplied is returned when applying a migration that is applied already
+var ErrApplied = errors.New("migration is already applied")
+
+// Migration is an applied migration as recorded by the Adapter
+type Migration struct {
+	Name      string
+	AppliedAt time.Time
+	// Rollback is the down script saved when the migration was applied
+	Rollback string
+	// Checksum of the up script
+	Checksum string
+}
+
+type LogFunc func(v ...any)
+
+type Option func(*Adapter)
+
+// failure is the key of an injected failure, migration is empty for failures of every call to the method
+type failure struct {
+	method    Method
+	migration string
+}
+
+// Adapter keeps track of applied migrations in memory, for testing code that runs migrations without a database.
+// Changes made in a transaction are only seen by the transaction until it is committed, and are discarded when it is
+// rolled back. It is safe for concurrent use, and Migrate instances sharing it are serialised by Lock.
+type Adapter struct {
+	mu       sync.Mutex
+	log      LogFunc
+	isSetup  bool
+	applied  []Migration
+	tx       []Migration
+	inTx     bool
+	failures map[failure]error
+	calls    []string
+	// lock holds a value while the migration lock is held
+	lock chan struct{}
+}
+
+// NewAdapter creates an Adapter without applied migrations
+func NewAdapter(options ...Option) *Adapter {
+	a := &Adapter{
+		log:      func(v ...any) {},
+		failures: map[failure]error{},
+		lock:     make(chan struct{}, 1),
+	}
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+// Fail makes calls to method fail with err, or no longer fail if err is nil. For Up, Down, RollbackSQL and
+// SetChecksum the failure is limited to the named migration, unless name is empty. A failing call changes nothing,
+// except that a failing Commit or Rollback still ends the transaction without committing it.
+func (a *Adapter) Fail(method Method, name string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err == nil {
+		delete(a.failures, failure{method: method, migration: name})
+		return
+	}
+	a.failures[failure{method: method, migration: name}] = err
+}
+
+// Applied returns the committed migrations, sorted by name
+func (a *Adapter) Applied() []Migration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.applied)
+}
+
+// Calls returns every call made to the adapter in order, such as "Begin", "Up aaa" and "Commit"
+func (a *Adapter) Calls() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.calls)
+}
+
+// InTransaction returns true while a transaction is open
+func (a *Adapter) InTransaction() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inTx
+}
+
+// call records the call and returns the failure injected for it, if any. a.mu must be held.
+func (a *Adapter) call(method Method, name string) error {
+	if name == "" {
+		a.calls = append(a.calls, string(method))
+	} else {
+		a.calls = append(a.calls, string(method)+" "+name)
+	}
+	if err, ok := a.failures[failure{method: method, migration: name}]; ok {
+		return fmt.Errorf("memory.Adapter %s failed: %w", method, err)
+	}
+	if err, ok := a.failures[failure{method: method}]; ok {
+		return fmt.Errorf("memory.Adapter %s failed: %w", method, err)
+	}
+	if needsSetup[method] && !a.isSetup {
+		return fmt.Errorf("memory.Adapter %s failed: Setup was not called", method)
+	}
+	return nil
+}
+
+// current returns the migrations seen by the current call, those of the transaction if one is open. a.mu must be held.
+func (a *Adapter) current() *[]Migration {
+	if a.inTx {
+		return &a.tx
+	}
+	return &a.applied
+}
+
+// find returns the index of the named migration in migrations, or where it would be inserted and false if it is not
+// there
+func find(migrations []Migration, name string) (int, bool) {
+	return slices.BinarySearchFunc(migrations, name, func(m Migration, name string) int {
+		return strings.Compare(m.Name, name)
+	})
+}
+
+func (a *Adapter) Setup() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodSetup, ""); err != nil {
+		return err
+	}
+	a.isSetup = true
+	return nil
+}
+
+func (a *Adapter) List() ([]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodList, ""); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range *a.current() {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+func (a *Adapter) Begin(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodBegin, ""); err != nil {
+		return err
+	}
+	if a.inTx {
+		return fmt.Errorf("memory.Adapter Begin failed: transaction already started")
+	}
+	a.tx = slices.Clone(a.applied)
+	a.inTx = true
+	return nil
+}
+
+func (a *Adapter) Commit() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodCommit, ""); err != nil {
+		// like database/sql, the transaction is over even if ending it failed
+		a.tx, a.inTx = nil, false
+		return err
+	}
+	if !a.inTx {
+		return fmt.Errorf("memory.Adapter Commit failed: no transaction to commit")
+	}
+	a.applied, a.tx, a.inTx = a.tx, nil, false
+	return nil
+}
+
+func (a *Adapter) Rollback() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodRollback, ""); err != nil {
+		// like database/sql, the transaction is over even if ending it failed
+		a.tx, a.inTx = nil, false
+		return err
+	}
+	if !a.inTx {
+		return fmt.Errorf("memory.Adapter Rollback failed: no transaction to commit")
+	}
+	a.tx, a.inTx = nil, false
+	return nil
+}
+
+// Up records the migration with its down script as rollback and the checksum of its up script
+func (a *Adapter) Up(name string, up, down io.Reader) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodUp, name); err != nil {
+		return err
+	}
+	a.log("Applying migration", name)
+
+	sum, err := checksum.Sum(up)
+	if err != nil {
+		return fmt.Errorf("memory.Adapter Up failed to read up file for migration '%s': %w", name, err)
+	}
+	var rollback []byte
+	if down != nil {
+		if rollback, err = io.ReadAll(down); err != nil {
+			return fmt.Errorf("memory.Adapter Up failed to read down file for migration '%s': %w", name, err)
+		}
+	}
+
+	migrations := a.current()
+	i, ok := find(*migrations, name)
+	if ok {
+		return fmt.Errorf("memory.Adapter Up failed to register migration '%s': %w", name, ErrApplied)
+	}
+	*migrations = slices.Insert(*migrations, i, Migration{Name: name, AppliedAt: time.Now(), Rollback: string(rollback), Checksum: sum})
+	return nil
+}
+
+// Down removes the migration, unless it was applied without a rollback
+func (a *Adapter) Down(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodDown, name); err != nil {
+		return err
+	}
+	a.log("Taking down migration", name)
+
+	migrations := a.current()
+	i, ok := find(*migrations, name)
+	if !ok {
+		return fmt.Errorf("memory.Adapter Down failed to get rollback sql: %w", ErrNotApplied)
+	}
+	if (*migrations)[i].Rollback == "" {
+		a.log("migration", name, "cannot be taken down because it does not have a rollback")
+		return nil
+	}
+	*migrations = slices.Delete(*migrations, i, i+1)
+	return nil
+}
+
+func (a *Adapter) RollbackSQL(name string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodRollbackSQL, name); err != nil {
+		return "", err
+	}
+	i, ok := find(a.applied, name)
+	if !ok {
+		return "", fmt.Errorf("memory.Adapter RollbackSQL failed to get rollback sql for migration '%s': %w", name, ErrNotApplied)
+	}
+	return a.applied[i].Rollback, nil
+}
+
+func (a *Adapter) Checksums() (map[string]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodChecksums, ""); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string)
+	for _, m := range a.applied {
+		sums[m.Name] = m.Checksum
+	}
+	return sums, nil
+}
+
+func (a *Adapter) SetChecksum(name, sum string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodSetChecksum, name); err != nil {
+		return err
+	}
+	i, ok := find(a.applied, name)
+	if !ok {
+		return fmt.Errorf("memory.Adapter SetChecksum failed for migration '%s': %w", name, ErrNotApplied)
+	}
+	a.applied[i].Checksum = sum
+	return nil
+}
+
+// Lock waits until the migration lock is released by whoever holds it or ctx is done
+func (a *Adapter) Lock(ctx context.Context) error {
+	a.mu.Lock()
+	err := a.call(MethodLock, "")
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case a.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("memory.Adapter Lock failed: %w", ctx.Err())
+	}
+}
+
+// Unlock releases the migration lock acquired by Lock
+func (a *Adapter) Unlock() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.call(MethodUnlock, ""); err != nil {
+		return err
+	}
+	select {
+	case <-a.lock:
+		return nil
+	default:
+		return fmt.Errorf("memory.Adapter Unlock failed: no lock to release")
+	}
+}
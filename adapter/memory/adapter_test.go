@@ -0,0 +1,259 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/mertenvg/migrate"
+	"github.com/mertenvg/migrate/pkg/checksum"
+	"github.com/mertenvg/migrate/provider/files"
+)
+
+var (
+	_ migrate.Adapter        = (*Adapter)(nil)
+	_ migrate.RollbackReader = (*Adapter)(nil)
+	_ migrate.Checksummer    = (*Adapter)(nil)
+	_ migrate.Locker         = (*Adapter)(nil)
+)
+
+func mustSum(s string) string {
+	sum, _ := checksum.Sum(bytes.NewBufferString(s))
+	return sum
+}
+
+func names(migrations []Migration) []string {
+	var n []string
+	for _, m := range migrations {
+		n = append(n, m.Name)
+	}
+	return n
+}
+
+func newAdapter(t *testing.T, options ...Option) *Adapter {
+	a := NewAdapter(options...)
+	if err := a.Setup(); err != nil {
+		t.Fatalf("Setup() unexpected error %v", err)
+	}
+	return a
+}
+
+func TestAdapter_WithoutSetup(t *testing.T) {
+	a := NewAdapter()
+	if _, err := a.List(); err == nil {
+		t.Errorf("List() error = %v, wantErr %v", err, true)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString(""), nil); err == nil {
+		t.Errorf("Up() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestAdapter_Up(t *testing.T) {
+	a := newAdapter(t)
+	if err := a.Up("bbb", bytes.NewBufferString("CREATE TABLE b ();"), bytes.NewBufferString("DROP TABLE b;")); err != nil {
+		t.Fatalf("Up() unexpected error %v", err)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString("CREATE TABLE a ();"), nil); err != nil {
+		t.Fatalf("Up() unexpected error %v", err)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString("CREATE TABLE a ();"), nil); !errors.Is(err, ErrApplied) {
+		t.Errorf("Up() error = %v, want %v", err, ErrApplied)
+	}
+
+	got, err := a.List()
+	if err != nil {
+		t.Fatalf("List() unexpected error %v", err)
+	}
+	if want := []string{"aaa", "bbb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+	rollback, err := a.RollbackSQL("bbb")
+	if err != nil || rollback != "DROP TABLE b;" {
+		t.Errorf("RollbackSQL() = %q, %v, want %q", rollback, err, "DROP TABLE b;")
+	}
+	sums, err := a.Checksums()
+	if err != nil {
+		t.Fatalf("Checksums() unexpected error %v", err)
+	}
+	if want := map[string]string{"aaa": mustSum("CREATE TABLE a ();"), "bbb": mustSum("CREATE TABLE b ();")}; !reflect.DeepEqual(sums, want) {
+		t.Errorf("Checksums() = %v, want %v", sums, want)
+	}
+}
+
+func TestAdapter_Down(t *testing.T) {
+	a := newAdapter(t, WithApplied(
+		Migration{Name: "aaa", Rollback: "DROP TABLE a;"},
+		Migration{Name: "bbb"},
+	))
+	if err := a.Down("bbb"); err != nil {
+		t.Errorf("Down() unexpected error %v", err)
+	}
+	if err := a.Down("aaa"); err != nil {
+		t.Errorf("Down() unexpected error %v", err)
+	}
+	if err := a.Down("ccc"); !errors.Is(err, ErrNotApplied) {
+		t.Errorf("Down() error = %v, want %v", err, ErrNotApplied)
+	}
+	// without a rollback bbb stays applied
+	if got, want := names(a.Applied()), []string{"bbb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Applied() = %v, want %v", got, want)
+	}
+}
+
+func TestAdapter_Transaction(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(a *Adapter) error
+		want   []string
+	}{
+		{
+			name:   "commit",
+			finish: (*Adapter).Commit,
+			want:   []string{"bbb"},
+		},
+		{
+			name:   "rollback",
+			finish: (*Adapter).Rollback,
+			want:   []string{"aaa"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdapter(t, WithApplied(Migration{Name: "aaa", Rollback: "DROP TABLE a;"}))
+			if err := a.Begin(context.Background()); err != nil {
+				t.Fatalf("Begin() unexpected error %v", err)
+			}
+			if err := a.Begin(context.Background()); err == nil {
+				t.Errorf("Begin() in a transaction error = %v, wantErr %v", err, true)
+			}
+			if err := a.Down("aaa"); err != nil {
+				t.Fatalf("Down() unexpected error %v", err)
+			}
+			if err := a.Up("bbb", bytes.NewBufferString(""), nil); err != nil {
+				t.Fatalf("Up() unexpected error %v", err)
+			}
+
+			// the transaction sees its own changes, the committed state does not change until it is committed
+			if got, _ := a.List(); !reflect.DeepEqual(got, []string{"bbb"}) {
+				t.Errorf("List() in transaction = %v, want %v", got, []string{"bbb"})
+			}
+			if got := names(a.Applied()); !reflect.DeepEqual(got, []string{"aaa"}) {
+				t.Errorf("Applied() in transaction = %v, want %v", got, []string{"aaa"})
+			}
+
+			if err := tt.finish(a); err != nil {
+				t.Fatalf("%s() unexpected error %v", tt.name, err)
+			}
+			if got := names(a.Applied()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Applied() = %v, want %v", got, tt.want)
+			}
+			if a.InTransaction() {
+				t.Errorf("InTransaction() = true after %s", tt.name)
+			}
+			if err := tt.finish(a); err == nil {
+				t.Errorf("%s() without a transaction error = %v, wantErr %v", tt.name, err, true)
+			}
+		})
+	}
+}
+
+func TestAdapter_Fail(t *testing.T) {
+	errFail := errors.New("injected")
+	a := newAdapter(t, WithFailure(MethodUp, "bbb", errFail))
+
+	if err := a.Up("aaa", bytes.NewBufferString(""), nil); err != nil {
+		t.Errorf("Up() unexpected error %v", err)
+	}
+	if err := a.Up("bbb", bytes.NewBufferString(""), nil); !errors.Is(err, errFail) {
+		t.Errorf("Up() error = %v, want %v", err, errFail)
+	}
+
+	a.Fail(MethodUp, "bbb", nil)
+	a.Fail(MethodCommit, "", errFail)
+	if err := a.Up("bbb", bytes.NewBufferString(""), nil); err != nil {
+		t.Errorf("Up() unexpected error %v", err)
+	}
+	if err := a.Begin(context.Background()); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	if err := a.Commit(); !errors.Is(err, errFail) {
+		t.Errorf("Commit() error = %v, want %v", err, errFail)
+	}
+	if a.InTransaction() {
+		t.Errorf("InTransaction() = true after a failed Commit")
+	}
+
+	want := []string{"Setup", "Up aaa", "Up bbb", "Up bbb", "Begin", "Commit"}
+	if got := a.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Calls() = %v, want %v", got, want)
+	}
+}
+
+func TestAdapter_Lock(t *testing.T) {
+	a := NewAdapter()
+	if err := a.Lock(context.Background()); err != nil {
+		t.Fatalf("Lock() unexpected error %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := a.Lock(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Lock() while locked error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if err := a.Unlock(); err != nil {
+		t.Errorf("Unlock() unexpected error %v", err)
+	}
+	if err := a.Unlock(); err == nil {
+		t.Errorf("Unlock() without the lock error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestAdapter_WithMigrate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_a.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"0001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"0002_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"0003_c.up.sql":   {Data: []byte("CREATE TABLE c ();")},
+	}
+	tests := []struct {
+		name    string
+		mode    migrate.TxMode
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "single transaction",
+			mode:    migrate.TxSingle,
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "transaction per migration",
+			mode:    migrate.TxPerMigration,
+			want:    []string{"0001_a", "0002_b"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAdapter(WithFailure(MethodUp, "0003_c", errors.New("injected")))
+			m := migrate.New(migrate.WithAdapter(a), migrate.WithProvider(files.NewFSProvider(fsys)), migrate.WithTxMode(tt.mode))
+
+			err := m.Migrate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := names(a.Applied()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Applied() = %v, want %v", got, tt.want)
+			}
+			if a.InTransaction() {
+				t.Errorf("InTransaction() = true after Migrate")
+			}
+		})
+	}
+}
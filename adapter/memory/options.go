@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"slices"
+)
+
+func WithLog(f LogFunc) Option {
+	return func(a *Adapter) {
+		a.log = f
+	}
+}
+
+// WithApplied starts the adapter with the migrations applied, as if they were committed before
+func WithApplied(migrations ...Migration) Option {
+	return func(a *Adapter) {
+		for _, m := range migrations {
+			if i, ok := find(a.applied, m.Name); ok {
+				a.applied[i] = m
+			} else {
+				a.applied = slices.Insert(a.applied, i, m)
+			}
+		}
+	}
+}
+
+// WithFailure makes calls to method fail with err, as Adapter.Fail does
+func WithFailure(method Method, name string, err error) Option {
+	return func(a *Adapter) {
+		a.failures[failure{method: method, migration: name}] = err
+	}
+}
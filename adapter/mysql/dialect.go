@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mertenvg/migrate/adapter/sqldb"
+)
+
+// implicitCommit matches the statements MySQL commits the current transaction for before running them, see
+// https://dev.mysql.com/doc/refman/8.0/en/implicit-commit.html
+var implicitCommit = regexp.MustCompile(`(?is)^(ALTER|CREATE|DROP|RENAME|TRUNCATE|GRANT|REVOKE|LOCK|UNLOCK|ANALYZE|OPTIMIZE|REPAIR|CHECK|CACHE|FLUSH|INSTALL|UNINSTALL)\b|^LOAD\s+INDEX\b|^SET\s+PASSWORD\b`)
+
+// temporaryTable matches the DDL on temporary tables, which does not commit implicitly
+var temporaryTable = regexp.MustCompile(`(?is)^(CREATE|DROP)\s+TEMPORARY\s+TABLE\b`)
+
+// storeExists returns whether the table exists in the database, or in the current database if the database is empty
+const storeExists = `
+	SELECT EXISTS (
+		SELECT 1 FROM information_schema.tables
+		WHERE table_schema = COALESCE(NULLIF(?, ''), DATABASE()) AND table_name = ?
+	)
+`
+
+// Dialect is the MySQL and MariaDB sqldb.Dialect.
+//
+// MySQL commits the current transaction before most DDL statements, so a migration containing one cannot be rolled
+// back with the others. sqldb.Adapter commits the migrations before it, and records it as soon as its statements ran,
+// so the migrations table always matches what was committed, even if a later migration fails.
+//
+// Scripts are split into statements by reader.SQLReader, which does not understand DELIMITER or backslash escaped
+// quotes, so escape quotes in strings by doubling them and create stored programs from a FuncMigration.
+type Dialect struct{}
+
+var (
+	_ sqldb.Dialect           = Dialect{}
+	_ sqldb.ImplicitCommitter = Dialect{}
+)
+
+// NewAdapter creates a sqldb.Adapter recording applied migrations in the "migrations" table of the MySQL or MariaDB
+// database
+func NewAdapter(db *sql.DB, options ...sqldb.Option) *sqldb.Adapter {
+	return sqldb.NewAdapter(db, Dialect{}, options...)
+}
+
+// Placeholder returns ?
+func (Dialect) Placeholder(n int) string {
+	return "?"
+}
+
+// Quote returns the identifier in backticks
+func (Dialect) Quote(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+// MigrationStore returns the migrations table as an InnoDB table, so recording a migration is transactional
+func (Dialect) MigrationStore(table string) []string {
+	return []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n"+
+		"	`name` VARCHAR(255) NOT NULL,\n"+
+		"	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,\n"+
+		"	`rollback` LONGTEXT NULL,\n"+
+		"	`checksum` VARCHAR(64) NULL,\n"+
+		"	PRIMARY KEY (`name`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", table)}
+}
+
+// StoreExists looks the table up in the given database, or in the current database if none is given
+func (Dialect) StoreExists(ctx context.Context, db *sql.DB, schema, table string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, storeExists, schema, table).Scan(&exists)
+	return exists, err
+}
+
+// Locker returns nil, migrators are not stopped from running at the same time
+func (Dialect) Locker() sqldb.Locker {
+	return nil
+}
+
+// NoTransaction returns false, the statements MySQL cannot run in a transaction commit it implicitly instead
+func (Dialect) NoTransaction(q string) bool {
+	return false
+}
+
+// ImplicitCommit returns true if MySQL commits the current transaction before running the statement
+func (Dialect) ImplicitCommit(q string) bool {
+	return implicitCommit.MatchString(q) && !temporaryTable.MatchString(q)
+}
@@ -0,0 +1,222 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/mertenvg/migrate"
+	"github.com/mertenvg/migrate/adapter/sqldb"
+	"github.com/mertenvg/migrate/adapter/sqldb/sqldbtest"
+)
+
+// queries are the statements sqldb.Adapter keeps track of migrations with, as the MySQL Dialect quotes them
+var queries = sqldbtest.Queries{
+	Store: []string{"CREATE TABLE IF NOT EXISTS `migrations` (\n" +
+		"	`name` VARCHAR(255) NOT NULL,\n" +
+		"	`created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,\n" +
+		"	`rollback` LONGTEXT NULL,\n" +
+		"	`checksum` VARCHAR(64) NULL,\n" +
+		"	PRIMARY KEY (`name`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+	Add:              "INSERT INTO `migrations` (`name`, `rollback`, `checksum`) VALUES (?, ?, ?)",
+	Migrations:       "SELECT `name` FROM `migrations` ORDER BY `name`",
+	Applied:          "SELECT `name` FROM `migrations` ORDER BY `created_at`, `name`",
+	Checksums:        "SELECT `name`, `checksum` FROM `migrations` ORDER BY `name`",
+	UpdateChecksum:   "UPDATE `migrations` SET `checksum` = ? WHERE `name` = ?",
+	RollbackWithName: "SELECT `rollback` FROM `migrations` WHERE `name` = ?",
+	RemoveWithName:   "DELETE FROM `migrations` WHERE `name` = ?",
+}
+
+func newAdapter(t *testing.T) (*sqldb.Adapter, sqlmock.Sqlmock) {
+	return sqldbtest.NewAdapter(t, Dialect{}, queries)
+}
+
+func TestDialect_ImplicitCommit(t *testing.T) {
+	tests := []struct {
+		q    string
+		want bool
+	}{
+		{q: "CREATE TABLE a (id INT)", want: true},
+		{q: "alter table a add column b int", want: true},
+		{q: "DROP INDEX b ON a", want: true},
+		{q: "RENAME TABLE a TO b", want: true},
+		{q: "TRUNCATE a", want: true},
+		{q: "LOCK TABLES a WRITE", want: true},
+		{q: "LOAD INDEX INTO CACHE a", want: true},
+		{q: "CREATE TEMPORARY TABLE t (id INT)", want: false},
+		{q: "DROP TEMPORARY TABLE t", want: false},
+		{q: "INSERT INTO a VALUES (1)", want: false},
+		{q: "UPDATE a SET created = NOW()", want: false},
+		{q: "LOAD DATA INFILE 'a.csv' INTO TABLE a", want: false},
+		{q: "DELETE FROM dropped", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			if got := (Dialect{}).ImplicitCommit(tt.q); got != tt.want {
+				t.Errorf("ImplicitCommit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdapter_Up(t *testing.T) {
+	tests := []struct {
+		name    string
+		up      string
+		expect  func(mock sqlmock.Sqlmock, up string)
+		wantErr bool
+	}{
+		{
+			name: "with implicit commit",
+			up:   "CREATE TABLE a (id INT);\nINSERT INTO a VALUES (1);",
+			expect: func(mock sqlmock.Sqlmock, up string) {
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(sqldbtest.MockFriendly("CREATE TABLE a (id INT)")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(sqldbtest.MockFriendly("INSERT INTO a VALUES (1)")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(sqldbtest.MockFriendly(queries.Add)).WithArgs("aaa", "DROP TABLE a;", sqldbtest.Sum(up)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+			},
+		},
+		{
+			name: "with implicit commit failing",
+			up:   "CREATE TABLE a (id INT);",
+			expect: func(mock sqlmock.Sqlmock, up string) {
+				mock.ExpectCommit()
+				mock.ExpectBegin()
+				mock.ExpectExec(sqldbtest.MockFriendly("CREATE TABLE a (id INT)")).WillReturnError(errors.New("table exists"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "with commit before implicit commit failing",
+			up:   "CREATE TABLE a (id INT);",
+			expect: func(mock sqlmock.Sqlmock, up string) {
+				mock.ExpectCommit().WillReturnError(errors.New("connection lost"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, mock := newAdapter(t)
+			mock.ExpectBegin()
+			tt.expect(mock, tt.up)
+
+			if err := a.Begin(context.Background()); err != nil {
+				t.Fatalf("Begin() unexpected error %v", err)
+			}
+			err := a.Up("aaa", bytes.NewBufferString(tt.up), bytes.NewBufferString("DROP TABLE a;"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Up() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+// TestAdapter_Up_RecordsEachMigration checks a failing migration leaves the migrations before it recorded and
+// committed when one of them caused an implicit commit
+func TestAdapter_Up_RecordsEachMigration(t *testing.T) {
+	a, mock := newAdapter(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(sqldbtest.MockFriendly("INSERT INTO a VALUES (1)")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(sqldbtest.MockFriendly(queries.Add)).WithArgs("aaa", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(sqldbtest.MockFriendly("ALTER TABLE a ADD b INT")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(sqldbtest.MockFriendly(queries.Add)).WithArgs("bbb", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(sqldbtest.MockFriendly("UPDATE a SET b = 1")).WillReturnError(errors.New("deadlock"))
+	mock.ExpectRollback()
+
+	if err := a.Begin(context.Background()); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString("INSERT INTO a VALUES (1);"), nil); err != nil {
+		t.Fatalf("Up() unexpected error %v", err)
+	}
+	if err := a.Up("bbb", bytes.NewBufferString("ALTER TABLE a ADD b INT;"), nil); err != nil {
+		t.Fatalf("Up() unexpected error %v", err)
+	}
+	err := a.Up("ccc", bytes.NewBufferString("UPDATE a SET b = 1;"), nil)
+	var incompleteErr *migrate.IncompleteError
+	if !errors.As(err, &incompleteErr) || !reflect.DeepEqual(incompleteErr.Committed, []string{"aaa", "bbb"}) {
+		t.Errorf("Up() error = %v, want IncompleteError for [aaa bbb]", err)
+	}
+	if err := a.Rollback(); err != nil {
+		t.Errorf("Rollback() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+// TestAdapter_Up_WithMigrationContext checks the transaction begun after an implicit commit outlives the context of
+// the migration that caused it
+func TestAdapter_Up_WithMigrationContext(t *testing.T) {
+	a, mock := newAdapter(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(sqldbtest.MockFriendly("ALTER TABLE a ADD b INT")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(sqldbtest.MockFriendly(queries.Add)).WithArgs("aaa", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(sqldbtest.MockFriendly("INSERT INTO a VALUES (1)")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(sqldbtest.MockFriendly(queries.Add)).WithArgs("bbb", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := a.Begin(context.Background()); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := a.UpContext(ctx, "aaa", bytes.NewBufferString("ALTER TABLE a ADD b INT;"), nil); err != nil {
+		t.Fatalf("UpContext() unexpected error %v", err)
+	}
+	cancel()
+	if err := a.UpContext(context.Background(), "bbb", bytes.NewBufferString("INSERT INTO a VALUES (1);"), nil); err != nil {
+		t.Errorf("UpContext() unexpected error %v", err)
+	}
+	if err := a.Commit(); err != nil {
+		t.Errorf("Commit() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Down_WithImplicitCommit(t *testing.T) {
+	a, mock := newAdapter(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(sqldbtest.MockFriendly(queries.RollbackWithName)).WithArgs("aaa").WillReturnRows(sqlmock.NewRows([]string{"rollback"}).AddRow("DROP TABLE a;"))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(sqldbtest.MockFriendly("DROP TABLE a")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(sqldbtest.MockFriendly(queries.RemoveWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	if err := a.Begin(context.Background()); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	if err := a.Down("aaa"); err != nil {
+		t.Errorf("Down() unexpected error %v", err)
+	}
+	if err := a.Commit(); err != nil {
+		t.Errorf("Commit() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -4,18 +4,175 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 
+	"github.com/mertenvg/migrate"
+	"github.com/mertenvg/migrate/adapter/sqldb"
+	"github.com/mertenvg/migrate/pkg/checksum"
 	"github.com/mertenvg/migrate/pkg/reader"
 )
 
+var (
+	_ migrate.Adapter               = (*Adapter)(nil)
+	_ migrate.ContextAdapter        = (*Adapter)(nil)
+	_ migrate.RollbackReader        = (*Adapter)(nil)
+	_ migrate.Checksummer           = (*Adapter)(nil)
+	_ migrate.ContextRollbackReader = (*Adapter)(nil)
+	_ migrate.ContextChecksummer    = (*Adapter)(nil)
+	_ migrate.Locker                = (*Adapter)(nil)
+	_ migrate.FuncAdapter           = (*Adapter)(nil)
+	_ migrate.StatementTimeouter    = (*Adapter)(nil)
+	_ migrate.RetryClassifier       = (*Adapter)(nil)
+	_ migrate.Inspector             = (*Adapter)(nil)
+)
+
+func TestAdapter_Lock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectCurrentSchema(mock)
+	mock.ExpectQuery(makeMockFriendly(tryLock)).WithArgs(lockKey).WillReturnRows(
+		sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false),
+	)
+	expectCurrentSchema(mock)
+	mock.ExpectQuery(makeMockFriendly(tryLock)).WithArgs(lockKey).WillReturnRows(
+		sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true),
+	)
+	expectCurrentSchema(mock)
+	mock.ExpectQuery(makeMockFriendly(unlock)).WithArgs(lockKey).WillReturnRows(
+		sqlmock.NewRows([]string{"pg_advisory_unlock"}).AddRow(true),
+	)
+
+	a := NewAdapter(db)
+
+	if err := a.Lock(context.Background()); err != nil {
+		t.Errorf("Lock() error = %v, wantErr %v", err, false)
+	}
+	if err := a.Lock(context.Background()); err == nil {
+		t.Errorf("Lock() while locked error = %v, wantErr %v", err, true)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Errorf("Unlock() error = %v, wantErr %v", err, false)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Lock_WithTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectCurrentSchema(mock)
+	mock.ExpectQuery(makeMockFriendly(tryLock)).WithArgs(lockKey).WillReturnRows(
+		sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false),
+	)
+
+	a := NewAdapter(db)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = a.Lock(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Lock() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("Lock() kept %d connections after failing", inUse)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Lock_WithQueryError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectCurrentSchema(mock)
+	mock.ExpectQuery(makeMockFriendly(tryLock)).WithArgs(lockKey).WillReturnError(errors.New("query error"))
+
+	a := NewAdapter(db)
+
+	wantErr := true
+	if err := a.Lock(context.Background()); (err != nil) != wantErr {
+		t.Errorf("Lock() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Unlock_WithoutLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	a := NewAdapter(db)
+
+	wantErr := true
+	if err := a.Unlock(); (err != nil) != wantErr {
+		t.Errorf("Unlock() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Unlock_WithLockNotHeld(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectCurrentSchema(mock)
+	mock.ExpectQuery(makeMockFriendly(tryLock)).WithArgs(lockKey).WillReturnRows(
+		sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true),
+	)
+	expectCurrentSchema(mock)
+	mock.ExpectQuery(makeMockFriendly(unlock)).WithArgs(lockKey).WillReturnRows(
+		sqlmock.NewRows([]string{"pg_advisory_unlock"}).AddRow(false),
+	)
+
+	a := NewAdapter(db)
+
+	if err := a.Lock(context.Background()); err != nil {
+		t.Errorf("Lock() error = %v, wantErr %v", err, false)
+	}
+	wantErr := true
+	if err := a.Unlock(); (err != nil) != wantErr {
+		t.Errorf("Unlock() error = %v, wantErr %v", err, wantErr)
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("Unlock() kept %d connections", inUse)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
 func TestAdapter_Begin(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -37,6 +194,55 @@ func TestAdapter_Begin(t *testing.T) {
 	}
 }
 
+func TestAdapter_Begin_WithTimeouts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("SET LOCAL lock_timeout = 1000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly("SET LOCAL statement_timeout = 2000")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	a := NewAdapter(db, sqldb.WithLockTimeout(time.Second), sqldb.WithStatementTimeout(2*time.Second))
+
+	wantErr := false
+	if err := a.Begin(context.Background()); (err != nil) != wantErr {
+		t.Errorf("Begin() error = %v, wantErr %v", err, wantErr)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Begin_WithTimeoutsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("SET LOCAL lock_timeout = 1000")).WillReturnError(errors.New("set error"))
+	mock.ExpectRollback()
+
+	a := NewAdapter(db, sqldb.WithLockTimeout(time.Second))
+
+	wantErr := true
+	if err := a.Begin(context.Background()); (err != nil) != wantErr {
+		t.Errorf("Begin() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := a.Commit(); err == nil {
+		t.Errorf("Begin() kept the transaction after failing")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
 func TestAdapter_Begin_WithNilContext(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -85,12 +291,8 @@ func TestAdapter_List(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
-	mock.ExpectQuery(makeMockFriendly(migrations)).WillReturnRows(
+	expectSetup(mock)
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.migrations)).WillReturnRows(
 		sqlmock.NewRows([]string{"name"}).AddRow("aaa").AddRow("bbb"),
 	)
 
@@ -123,12 +325,8 @@ func TestAdapter_List_WithQueryError(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
-	mock.ExpectQuery(makeMockFriendly(migrations)).WillReturnError(errors.New("query error"))
+	expectSetup(mock)
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.migrations)).WillReturnError(errors.New("query error"))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -159,12 +357,8 @@ func TestAdapter_List_WithScanError(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
-	mock.ExpectQuery(makeMockFriendly(migrations)).WillReturnRows(
+	expectSetup(mock)
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.migrations)).WillReturnRows(
 		sqlmock.NewRows([]string{"name"}).AddRow(nil),
 	)
 
@@ -190,24 +384,17 @@ func TestAdapter_List_WithScanError(t *testing.T) {
 	}
 }
 
-func TestAdapter_Down(t *testing.T) {
+func TestAdapter_Checksums(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
-	mock.ExpectBegin()
-	mock.ExpectQuery(makeMockFriendly(rollbackWithName)).WithArgs("aaa").WillReturnRows(
-		sqlmock.NewRows([]string{"rollback"}).AddRow("rollback aaa"),
+	expectSetup(mock)
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.checksums)).WillReturnRows(
+		sqlmock.NewRows([]string{"name", "checksum"}).AddRow("aaa", "sum aaa").AddRow("bbb", nil),
 	)
-	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(makeMockFriendly(removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -215,37 +402,31 @@ func TestAdapter_Down(t *testing.T) {
 		t.Errorf("unexpected error %v", err)
 		return
 	}
-	err = a.Begin(nil)
-	if err != nil {
-		t.Errorf("unexpected error %v", err)
-		return
-	}
 
 	wantErr := false
+	want := map[string]string{"aaa": "sum aaa", "bbb": ""}
 
-	err = a.Down("aaa")
+	got, err := a.Checksums()
 	if (err != nil) != wantErr {
-		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("Checksums() error = %v, wantErr %v", err, wantErr)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Checksums() = %v, want %v", got, want)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-func TestAdapter_Down_WithQueryError(t *testing.T) {
+func TestAdapter_Checksums_WithQueryError(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
-	mock.ExpectBegin()
-	mock.ExpectQuery(makeMockFriendly(rollbackWithName)).WithArgs("aaa").WillReturnError(errors.New("query error"))
+	expectSetup(mock)
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.checksums)).WillReturnError(errors.New("query error"))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -253,39 +434,28 @@ func TestAdapter_Down_WithQueryError(t *testing.T) {
 		t.Errorf("unexpected error %v", err)
 		return
 	}
-	err = a.Begin(nil)
-	if err != nil {
-		t.Errorf("unexpected error %v", err)
-		return
-	}
 
 	wantErr := true
 
-	err = a.Down("aaa")
+	_, err = a.Checksums()
 	if (err != nil) != wantErr {
-		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("Checksums() error = %v, wantErr %v", err, wantErr)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-func TestAdapter_Down_WithEmptyRollback(t *testing.T) {
+func TestAdapter_SetChecksum(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
-	mock.ExpectBegin()
-	mock.ExpectQuery(makeMockFriendly(rollbackWithName)).WithArgs("aaa").WillReturnRows(
-		sqlmock.NewRows([]string{"rollback"}).AddRow(""),
-	)
+	expectSetup(mock)
+	mock.ExpectExec(makeMockFriendly(defaultQueries.updateChecksum)).WithArgs("sum aaa", "aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.updateChecksum)).WithArgs("sum bbb", "bbb").WillReturnError(errors.New("exec error"))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -293,41 +463,61 @@ func TestAdapter_Down_WithEmptyRollback(t *testing.T) {
 		t.Errorf("unexpected error %v", err)
 		return
 	}
-	err = a.Begin(nil)
+
+	if err := a.SetChecksum("aaa", "sum aaa"); err != nil {
+		t.Errorf("SetChecksum() error = %v, wantErr %v", err, false)
+	}
+	if err := a.SetChecksum("bbb", "sum bbb"); err == nil {
+		t.Errorf("SetChecksum() error = %v, wantErr %v", err, true)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_RollbackSQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(
+		sqlmock.NewRows([]string{"rollback"}).AddRow("rollback aaa"),
+	)
+
+	a := NewAdapter(db)
+	err = a.Setup()
 	if err != nil {
 		t.Errorf("unexpected error %v", err)
 		return
 	}
 
 	wantErr := false
+	want := "rollback aaa"
 
-	err = a.Down("aaa")
+	got, err := a.RollbackSQL("aaa")
 	if (err != nil) != wantErr {
-		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("RollbackSQL() error = %v, wantErr %v", err, wantErr)
+	}
+	if got != want {
+		t.Errorf("RollbackSQL() = %v, want %v", got, want)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-func TestAdapter_Down_WithSQLReaderFail(t *testing.T) {
+func TestAdapter_RollbackSQL_WithQueryError(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
-	mock.ExpectBegin()
-	mock.ExpectQuery(makeMockFriendly(rollbackWithName)).WithArgs("aaa").WillReturnRows(
-		sqlmock.NewRows([]string{"rollback"}).AddRow("rollback aaa"),
-	)
-	// mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
-	// mock.ExpectExec(makeMockFriendly(removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSetup(mock)
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnError(sql.ErrNoRows)
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -335,43 +525,32 @@ func TestAdapter_Down_WithSQLReaderFail(t *testing.T) {
 		t.Errorf("unexpected error %v", err)
 		return
 	}
-	err = a.Begin(nil)
-	if err != nil {
-		t.Errorf("unexpected error %v", err)
-		return
-	}
 
 	wantErr := true
 
-	reader.FailNext = errors.New("fail next")
-
-	err = a.Down("aaa")
+	_, err = a.RollbackSQL("aaa")
 	if (err != nil) != wantErr {
-		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("RollbackSQL() error = %v, wantErr %v", err, wantErr)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-func TestAdapter_Down_WithBeginAndCommit(t *testing.T) {
+func TestAdapter_Down(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
-	mock.ExpectQuery(makeMockFriendly(rollbackWithName)).WithArgs("aaa").WillReturnRows(
-		sqlmock.NewRows([]string{"rollback"}).AddRow("begin; rollback aaa; commit;"),
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(
+		sqlmock.NewRows([]string{"rollback"}).AddRow("rollback aaa"),
 	)
 	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(makeMockFriendly(removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -396,24 +575,16 @@ func TestAdapter_Down_WithBeginAndCommit(t *testing.T) {
 	}
 }
 
-func TestAdapter_Down_WithQueryFail(t *testing.T) {
+func TestAdapter_Down_WithQueryError(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
-	mock.ExpectQuery(makeMockFriendly(rollbackWithName)).WithArgs("aaa").WillReturnRows(
-		sqlmock.NewRows([]string{"rollback"}).AddRow("begin; rollback aaa; commit;"),
-	)
-	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnError(errors.New("fail rollback aaa"))
-	// mock.ExpectExec(makeMockFriendly(removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnError(errors.New("query error"))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -438,24 +609,18 @@ func TestAdapter_Down_WithQueryFail(t *testing.T) {
 	}
 }
 
-func TestAdapter_Down_WithSaveStateFail(t *testing.T) {
+func TestAdapter_Down_WithEmptyRollback(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
-	mock.ExpectQuery(makeMockFriendly(rollbackWithName)).WithArgs("aaa").WillReturnRows(
-		sqlmock.NewRows([]string{"rollback"}).AddRow("begin; rollback aaa; commit;"),
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(
+		sqlmock.NewRows([]string{"rollback"}).AddRow(""),
 	)
-	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(makeMockFriendly(removeWithName)).WithArgs("aaa").WillReturnError(errors.New("fail"))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -469,7 +634,7 @@ func TestAdapter_Down_WithSaveStateFail(t *testing.T) {
 		return
 	}
 
-	wantErr := true
+	wantErr := false
 
 	err = a.Down("aaa")
 	if (err != nil) != wantErr {
@@ -480,21 +645,20 @@ func TestAdapter_Down_WithSaveStateFail(t *testing.T) {
 	}
 }
 
-func TestAdapter_Up(t *testing.T) {
+func TestAdapter_Down_WithSQLReaderFail(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
-	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(makeMockFriendly(add)).WithArgs("aaa", "rollback aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(
+		sqlmock.NewRows([]string{"rollback"}).AddRow("rollback aaa"),
+	)
+	// mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	// mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -508,30 +672,33 @@ func TestAdapter_Up(t *testing.T) {
 		return
 	}
 
-	wantErr := false
+	wantErr := true
 
-	err = a.Up("aaa", bytes.NewBufferString("apply aaa"), bytes.NewBufferString("rollback aaa"))
+	reader.FailNext = errors.New("fail next")
+
+	err = a.Down("aaa")
 	if (err != nil) != wantErr {
-		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-func TestAdapter_Up_WithSQLReaderFail(t *testing.T) {
+func TestAdapter_Down_WithBeginAndCommit(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(
+		sqlmock.NewRows([]string{"rollback"}).AddRow("begin; rollback aaa; commit;"),
+	)
+	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -545,34 +712,31 @@ func TestAdapter_Up_WithSQLReaderFail(t *testing.T) {
 		return
 	}
 
-	wantErr := true
-
-	reader.FailNext = errors.New("fail")
+	wantErr := false
 
-	err = a.Up("aaa", bytes.NewBufferString("apply aaa"), bytes.NewBufferString("rollback aaa"))
+	err = a.Down("aaa")
 	if (err != nil) != wantErr {
-		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-func TestAdapter_Up_WithBeginAndCommit(t *testing.T) {
+func TestAdapter_Down_WithQueryFail(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
-	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(makeMockFriendly(add)).WithArgs("aaa", "rollback aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(
+		sqlmock.NewRows([]string{"rollback"}).AddRow("begin; rollback aaa; commit;"),
+	)
+	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnError(errors.New("fail rollback aaa"))
+	// mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -586,32 +750,31 @@ func TestAdapter_Up_WithBeginAndCommit(t *testing.T) {
 		return
 	}
 
-	wantErr := false
+	wantErr := true
 
-	err = a.Up("aaa", bytes.NewBufferString("begin; apply aaa; commit;"), bytes.NewBufferString("rollback aaa"))
+	err = a.Down("aaa")
 	if (err != nil) != wantErr {
-		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-func TestAdapter_Up_WithNilDownArg(t *testing.T) {
+func TestAdapter_Down_WithSaveStateFail(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
-	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(makeMockFriendly(add)).WithArgs("aaa", "").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(
+		sqlmock.NewRows([]string{"rollback"}).AddRow("begin; rollback aaa; commit;"),
+	)
+	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("aaa").WillReturnError(errors.New("fail"))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -625,37 +788,517 @@ func TestAdapter_Up_WithNilDownArg(t *testing.T) {
 		return
 	}
 
-	wantErr := false
+	wantErr := true
+
+	err = a.Down("aaa")
+	if (err != nil) != wantErr {
+		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "rollback aaa", mustSum("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+	err = a.Begin(nil)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := false
+
+	err = a.Up("aaa", bytes.NewBufferString("apply aaa"), bytes.NewBufferString("rollback aaa"))
+	if (err != nil) != wantErr {
+		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_WithProgress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly("update aaa")).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "rollback aaa;", mustSum("BEGIN;\napply aaa;\nupdate aaa;\nCOMMIT;")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(sqlmock.NewRows([]string{"rollback"}).AddRow("rollback aaa;"))
+	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var got []Progress
+	a := NewAdapter(db, sqldb.WithProgress(func(p Progress) {
+		if p.Elapsed < p.Duration {
+			t.Errorf("Progress elapsed %v is less than the statement duration %v", p.Elapsed, p.Duration)
+		}
+		p.Duration, p.Elapsed = 0, 0
+		got = append(got, p)
+	}))
+	if err := a.Setup(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := a.Begin(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString("BEGIN;\napply aaa;\nupdate aaa;\nCOMMIT;"), bytes.NewBufferString("rollback aaa;")); err != nil {
+		t.Fatalf("Up() unexpected error %v", err)
+	}
+	if err := a.Down("aaa"); err != nil {
+		t.Fatalf("Down() unexpected error %v", err)
+	}
+
+	want := []Progress{
+		{Migration: "aaa", Direction: migrate.DirectionUp, Statement: 1, Total: 2},
+		{Migration: "aaa", Direction: migrate.DirectionUp, Statement: 2, Total: 2, RowsAffected: 3},
+		{Migration: "aaa", Direction: migrate.DirectionDown, Statement: 1, Total: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Progress reported %+v, want %+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithLogger(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly("update aaa")).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "", mustSum("apply aaa;\nupdate aaa;")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	var buf bytes.Buffer
+	a := NewAdapter(db, sqldb.WithLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	if err := a.Setup(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := a.Begin(nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString("apply aaa;\nupdate aaa;"), nil); err != nil {
+		t.Fatalf("Up() unexpected error %v", err)
+	}
+
+	type record struct {
+		Msg          string
+		Migration    string
+		Direction    string
+		Statement    int
+		Statements   int
+		RowsAffected int64 `json:"rows_affected"`
+	}
+	var got []record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r record
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("cannot decode log record: %v", err)
+		}
+		got = append(got, r)
+	}
+	want := []record{
+		{Msg: "applying migration", Migration: "aaa"},
+		{Msg: "statement executed", Migration: "aaa", Direction: "up", Statement: 1},
+		{Msg: "statement executed", Migration: "aaa", Direction: "up", Statement: 2, RowsAffected: 3},
+		{Msg: "migration statements executed", Migration: "aaa", Direction: "up", Statements: 2, RowsAffected: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Up() logged %+v, want %+v", got, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithSQLReaderFail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+	err = a.Begin(nil)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := true
+
+	reader.FailNext = errors.New("fail")
+
+	err = a.Up("aaa", bytes.NewBufferString("apply aaa"), bytes.NewBufferString("rollback aaa"))
+	if (err != nil) != wantErr {
+		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithBeginAndCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "rollback aaa", mustSum("begin; apply aaa; commit;")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+	err = a.Begin(nil)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := false
+
+	err = a.Up("aaa", bytes.NewBufferString("begin; apply aaa; commit;"), bytes.NewBufferString("rollback aaa"))
+	if (err != nil) != wantErr {
+		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithNilDownArg(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "", mustSum("begin; apply aaa; commit;")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+	err = a.Begin(nil)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := false
+
+	err = a.Up("aaa", bytes.NewBufferString("begin; apply aaa; commit;"), nil)
+	if (err != nil) != wantErr {
+		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+type FailReader struct{}
+
+func (r FailReader) Read(_ []byte) (n int, err error) {
+	return 0, errors.New("fail")
+}
+
+func TestAdapter_Up_WithDownReadFail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	// the down file is read before any statement of the up file runs
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+	err = a.Begin(nil)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := true
+
+	err = a.Up("aaa", bytes.NewBufferString("begin; apply aaa; commit;"), &FailReader{})
+	if (err != nil) != wantErr {
+		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithSaveStateFail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "rollback aaa", mustSum("apply aaa")).WillReturnError(errors.New("fail"))
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+	err = a.Begin(nil)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := true
+
+	err = a.Up("aaa", bytes.NewBufferString("apply aaa"), bytes.NewBufferString("rollback aaa"))
+	if (err != nil) != wantErr {
+		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithoutTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "rollback aaa", mustSum("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := false
+
+	err = a.Up("aaa", bytes.NewBufferString("apply aaa"), bytes.NewBufferString("rollback aaa"))
+	if (err != nil) != wantErr {
+		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Down_WithoutTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectQuery(makeMockFriendly(defaultQueries.rollbackWithName)).WithArgs("aaa").WillReturnRows(
+		sqlmock.NewRows([]string{"rollback"}).AddRow("rollback aaa"),
+	)
+	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := false
+
+	err = a.Down("aaa")
+	if (err != nil) != wantErr {
+		t.Errorf("Down() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_UpFunc(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "", "").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+	err = a.Begin(nil)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := false
+
+	err = a.UpFunc(context.Background(), "aaa", func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "apply aaa")
+		return err
+	})
+	if (err != nil) != wantErr {
+		t.Errorf("UpFunc() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_UpFunc_WithoutTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "", "").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := false
+
+	err = a.UpFunc(context.Background(), "aaa", func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "apply aaa")
+		return err
+	})
+	if (err != nil) != wantErr {
+		t.Errorf("UpFunc() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_UpFunc_WithFuncError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
 
-	err = a.Up("aaa", bytes.NewBufferString("begin; apply aaa; commit;"), nil)
+	expectSetup(mock)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	a := NewAdapter(db)
+	err = a.Setup()
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+		return
+	}
+
+	wantErr := true
+
+	err = a.UpFunc(context.Background(), "aaa", func(ctx context.Context, tx *sql.Tx) error {
+		return errors.New("func error")
+	})
 	if (err != nil) != wantErr {
-		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("UpFunc() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := a.UpFunc(context.Background(), "aaa", nil); err == nil {
+		t.Errorf("UpFunc() without func error = %v, wantErr %v", err, true)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-type FailReader struct{}
-
-func (r FailReader) Read(_ []byte) (n int, err error) {
-	return 0, errors.New("fail")
-}
-
-func TestAdapter_Up_WithDownReadFail(t *testing.T) {
+func TestAdapter_DownFunc(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
-	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly("rollback aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.removeWithName)).WithArgs("bbb").WillReturnResult(sqlmock.NewResult(0, 1))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -669,32 +1312,33 @@ func TestAdapter_Up_WithDownReadFail(t *testing.T) {
 		return
 	}
 
-	wantErr := true
+	wantErr := false
 
-	err = a.Up("aaa", bytes.NewBufferString("begin; apply aaa; commit;"), &FailReader{})
+	err = a.DownFunc(context.Background(), "aaa", func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "rollback aaa")
+		return err
+	})
 	if (err != nil) != wantErr {
-		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
+		t.Errorf("DownFunc() error = %v, wantErr %v", err, wantErr)
+	}
+	err = a.DownFunc(context.Background(), "bbb", nil)
+	if (err != nil) != wantErr {
+		t.Errorf("DownFunc() without func error = %v, wantErr %v", err, wantErr)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %v", err)
 	}
 }
 
-func TestAdapter_Up_WithSaveStateFail(t *testing.T) {
+func TestAdapter_UpContext_WithCancelledContext(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
-	mock.ExpectExec(makeMockFriendly("apply aaa")).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectExec(makeMockFriendly(add)).WithArgs("aaa", "rollback aaa").WillReturnError(errors.New("fail"))
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -708,10 +1352,220 @@ func TestAdapter_Up_WithSaveStateFail(t *testing.T) {
 		return
 	}
 
-	wantErr := true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	err = a.Up("aaa", bytes.NewBufferString("apply aaa"), bytes.NewBufferString("rollback aaa"))
-	if (err != nil) != wantErr {
+	err = a.UpContext(ctx, "aaa", bytes.NewBufferString("apply aaa"), bytes.NewBufferString("rollback aaa"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("UpContext() error = %v, want %v", err, context.Canceled)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_SetupContext_WithCancelledContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	a := NewAdapter(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = a.SetupContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("SetupContext() error = %v, want %v", err, context.Canceled)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_InspectContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		schema  string
+		exists  bool
+	}{
+		{name: "missing", exists: false},
+		{name: "missing with schema", options: []Option{sqldb.WithSchema("app")}, schema: "app", exists: false},
+		{name: "exists", exists: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer MustClose(db, nil)
+
+			mock.ExpectQuery(makeMockFriendly(storeExists)).WithArgs(tt.schema, "migrations").WillReturnRows(
+				sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists),
+			)
+			if tt.exists {
+				mock.ExpectPrepare(makeMockFriendly(defaultQueries.add))
+				mock.ExpectPrepare(makeMockFriendly(defaultQueries.migrations))
+				mock.ExpectPrepare(makeMockFriendly(defaultQueries.applied))
+				mock.ExpectPrepare(makeMockFriendly(defaultQueries.checksums))
+				mock.ExpectPrepare(makeMockFriendly(defaultQueries.updateChecksum))
+				mock.ExpectPrepare(makeMockFriendly(defaultQueries.rollbackWithName))
+				mock.ExpectPrepare(makeMockFriendly(defaultQueries.removeWithName))
+			}
+
+			got, err := NewAdapter(db, tt.options...).InspectContext(context.Background())
+			if err != nil {
+				t.Fatalf("InspectContext() unexpected error %v", err)
+			}
+			if got != tt.exists {
+				t.Errorf("InspectContext() = %v, want %v", got, tt.exists)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+type SQLStateError string
+
+func (e SQLStateError) Error() string {
+	return "sqlstate " + string(e)
+}
+
+func (e SQLStateError) SQLState() string {
+	return string(e)
+}
+
+func TestAdapter_Up_WithStatementTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []Option
+		expect      func(e *sqlmock.ExpectedExec)
+		wantTimeout time.Duration
+		// set and reset are the timeouts set on the connection, as Up runs without a transaction
+		set, reset string
+	}{
+		{
+			name:    "statement runs past the timeout",
+			options: []Option{sqldb.WithStatementTimeout(10 * time.Millisecond)},
+			expect: func(e *sqlmock.ExpectedExec) {
+				e.WillDelayFor(time.Second).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantTimeout: 10 * time.Millisecond,
+			set:         "SET statement_timeout = 10",
+			reset:       "RESET statement_timeout",
+		},
+		{
+			name:    "statement_timeout exceeded",
+			options: []Option{sqldb.WithStatementTimeout(time.Second)},
+			expect: func(e *sqlmock.ExpectedExec) {
+				e.WillReturnError(SQLStateError(queryCanceled))
+			},
+			wantTimeout: time.Second,
+			set:         "SET statement_timeout = 1000",
+			reset:       "RESET statement_timeout",
+		},
+		{
+			name:    "lock_timeout exceeded",
+			options: []Option{sqldb.WithLockTimeout(2 * time.Second)},
+			expect: func(e *sqlmock.ExpectedExec) {
+				e.WillReturnError(SQLStateError(lockNotAvailable))
+			},
+			wantTimeout: 2 * time.Second,
+			set:         "SET lock_timeout = 2000",
+			reset:       "RESET lock_timeout",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer MustClose(db, nil)
+
+			expectSetup(mock)
+			mock.ExpectExec(makeMockFriendly(tt.set)).WillReturnResult(sqlmock.NewResult(0, 0))
+			tt.expect(mock.ExpectExec(makeMockFriendly("apply aaa")))
+			mock.ExpectExec(makeMockFriendly(tt.reset)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+			a := NewAdapter(db, tt.options...)
+			err = a.Setup()
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+				return
+			}
+
+			err = a.Up("aaa", bytes.NewBufferString("apply aaa"), nil)
+			var te *migrate.TimeoutError
+			if !errors.As(err, &te) {
+				t.Fatalf("Up() error = %v, want *migrate.TimeoutError", err)
+			}
+			if te.Statement != "apply aaa" || te.Timeout != tt.wantTimeout {
+				t.Errorf("Up() error = %+v, want statement 'apply aaa' and timeout %v", te, tt.wantTimeout)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdapter_Up_WithoutTransaction_WithTimeouts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	// without a transaction, as with migrate.TxNone, SET LOCAL would do nothing, so the timeouts are set on the
+	// connection the migration runs on and reset before it is returned to the pool
+	expectSetup(mock)
+	mock.ExpectExec(makeMockFriendly("SET lock_timeout = 1000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly("SET statement_timeout = 2000")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly("CREATE INDEX CONCURRENTLY a_b ON a (b)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.add)).WithArgs("aaa", "", mustSum("CREATE INDEX CONCURRENTLY a_b ON a (b);")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(makeMockFriendly("RESET lock_timeout")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly("RESET statement_timeout")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	a := NewAdapter(db, sqldb.WithLockTimeout(time.Second), sqldb.WithStatementTimeout(2*time.Second))
+	if err := a.Setup(); err != nil {
+		t.Fatalf("Setup() unexpected error %v", err)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString("CREATE INDEX CONCURRENTLY a_b ON a (b);"), nil); err != nil {
+		t.Errorf("Up() unexpected error %v", err)
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("Up() kept %d connections", inUse)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithoutTransaction_WithTimeoutsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	expectSetup(mock)
+	mock.ExpectExec(makeMockFriendly("SET lock_timeout = 1000")).WillReturnError(errors.New("set error"))
+	// whatever was set is reset before the connection is returned to the pool
+	mock.ExpectExec(makeMockFriendly("RESET lock_timeout")).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	a := NewAdapter(db, sqldb.WithLockTimeout(time.Second))
+	if err := a.Setup(); err != nil {
+		t.Fatalf("Setup() unexpected error %v", err)
+	}
+	wantErr := true
+	if err := a.Up("aaa", bytes.NewBufferString("apply aaa"), nil); (err != nil) != wantErr {
 		t.Errorf("Up() error = %v, wantErr %v", err, wantErr)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -719,6 +1573,30 @@ func TestAdapter_Up_WithSaveStateFail(t *testing.T) {
 	}
 }
 
+func TestAdapter_Retryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "lock timeout", err: SQLStateError(lockNotAvailable), want: true},
+		{name: "serialization failure", err: SQLStateError(serializationFailure), want: true},
+		{name: "deadlock", err: SQLStateError(deadlockDetected), want: true},
+		{name: "wrapped lock timeout", err: &migrate.TimeoutError{Err: SQLStateError(lockNotAvailable)}, want: true},
+		{name: "statement timeout", err: SQLStateError(queryCanceled), want: false},
+		{name: "syntax error", err: SQLStateError("42601"), want: false},
+		{name: "no sqlstate", err: errors.New("connection reset"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAdapter(nil)
+			if got := a.Retryable(tt.err); got != tt.want {
+				t.Errorf("Retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestAdapter_Commit(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -726,11 +1604,7 @@ func TestAdapter_Commit(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
 	mock.ExpectCommit()
 
@@ -764,11 +1638,7 @@ func TestAdapter_Commit_WithoutTransaction(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -795,11 +1665,7 @@ func TestAdapter_Commit_WithFail(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
 	mock.ExpectCommit().WillReturnError(errors.New("fail"))
 
@@ -833,11 +1699,7 @@ func TestAdapter_Rollback(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
 	mock.ExpectRollback()
 
@@ -871,11 +1733,7 @@ func TestAdapter_Rollback_WithoutTransaction(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 
 	a := NewAdapter(db)
 	err = a.Setup()
@@ -902,11 +1760,7 @@ func TestAdapter_Rollback_WithFail(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 	mock.ExpectBegin()
 	mock.ExpectRollback().WillReturnError(errors.New("fail"))
 
@@ -935,10 +1789,59 @@ func TestAdapter_Rollback_WithFail(t *testing.T) {
 
 var matchWhitespace = regexp.MustCompile("\\s+")
 
+// defaultQueries and lockKey are used by an Adapter without WithTable and WithSchema
+var (
+	defaultQueries = struct {
+		store            []string
+		add              string
+		migrations       string
+		applied          string
+		checksums        string
+		updateChecksum   string
+		rollbackWithName string
+		removeWithName   string
+	}{
+		store:            Dialect{}.MigrationStore(`"migrations"`),
+		add:              `INSERT INTO "migrations" ("name", "rollback", "checksum") VALUES ($1, $2, $3)`,
+		migrations:       `SELECT "name" FROM "migrations" ORDER BY "name"`,
+		applied:          `SELECT "name" FROM "migrations" ORDER BY "created_at", "name"`,
+		checksums:        `SELECT "name", "checksum" FROM "migrations" ORDER BY "name"`,
+		updateChecksum:   `UPDATE "migrations" SET "checksum" = $1 WHERE "name" = $2`,
+		rollbackWithName: `SELECT "rollback" FROM "migrations" WHERE "name" = $1`,
+		removeWithName:   `DELETE FROM "migrations" WHERE "name" = $1`,
+	}
+	lockKey = advisoryLockKey("public.migrations")
+)
+
 func makeMockFriendly(s string) string {
 	return regexp.QuoteMeta(strings.TrimSpace(matchWhitespace.ReplaceAllString(s, " ")))
 }
 
+func mustSum(s string) string {
+	sum, _ := checksum.Sum(bytes.NewBufferString(s))
+	return sum
+}
+
+// expectCurrentSchema expects the schema of the migrations table to be resolved, as public
+func expectCurrentSchema(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(makeMockFriendly(currentSchema)).WillReturnRows(
+		sqlmock.NewRows([]string{"current_schema"}).AddRow("public"),
+	)
+}
+
+func expectSetup(mock sqlmock.Sqlmock) {
+	for _, q := range defaultQueries.store {
+		mock.ExpectExec(makeMockFriendly(q)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectPrepare(makeMockFriendly(defaultQueries.add))
+	mock.ExpectPrepare(makeMockFriendly(defaultQueries.migrations))
+	mock.ExpectPrepare(makeMockFriendly(defaultQueries.applied))
+	mock.ExpectPrepare(makeMockFriendly(defaultQueries.checksums))
+	mock.ExpectPrepare(makeMockFriendly(defaultQueries.updateChecksum))
+	mock.ExpectPrepare(makeMockFriendly(defaultQueries.rollbackWithName))
+	mock.ExpectPrepare(makeMockFriendly(defaultQueries.removeWithName))
+}
+
 func TestAdapter_Setup(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -946,11 +1849,7 @@ func TestAdapter_Setup(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add))
-	mock.ExpectPrepare(makeMockFriendly(migrations))
-	mock.ExpectPrepare(makeMockFriendly(rollbackWithName))
-	mock.ExpectPrepare(makeMockFriendly(removeWithName))
+	expectSetup(mock)
 
 	a := NewAdapter(db)
 
@@ -970,7 +1869,28 @@ func TestAdapter_Setup_FailExec(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnError(errors.New("test error"))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.store[0])).WillReturnError(errors.New("test error"))
+
+	a := NewAdapter(db)
+
+	wantErr := true
+	if err := a.Setup(); (err != nil) != wantErr {
+		t.Errorf("Setup() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Setup_FailAddChecksumColumn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	mock.ExpectExec(makeMockFriendly(defaultQueries.store[0])).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(defaultQueries.store[1])).WillReturnError(errors.New("test error"))
 
 	a := NewAdapter(db)
 
@@ -990,8 +1910,10 @@ func TestAdapter_Setup_FailPrepare(t *testing.T) {
 	}
 	defer MustClose(db, nil)
 
-	mock.ExpectExec(makeMockFriendly(migrationStore)).WillReturnResult(sqlmock.NewResult(0, 0))
-	mock.ExpectPrepare(makeMockFriendly(add)).WillReturnError(errors.New("prepare error"))
+	for _, q := range defaultQueries.store {
+		mock.ExpectExec(makeMockFriendly(q)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectPrepare(makeMockFriendly(defaultQueries.add)).WillReturnError(errors.New("prepare error"))
 
 	a := NewAdapter(db)
 
@@ -1048,51 +1970,174 @@ func TestMustClose(t *testing.T) {
 }
 
 func TestNewAdapter(t *testing.T) {
-	db, _, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer MustClose(db, nil)
-
 	logFunc := func(v ...any) { fmt.Println(v...) }
 	txo := &sql.TxOptions{Isolation: sql.LevelReadCommitted}
-	type args struct {
-		db      *sql.DB
-		options []Option
-	}
 	tests := []struct {
-		name string
-		args args
-		want *Adapter
+		name    string
+		options []Option
 	}{
 		{
 			name: "Test without options",
-			args: args{
-				db: db,
-				options: []Option{
-					WithLog(logFunc),
-				},
+			options: []Option{
+				WithLog(logFunc),
 			},
-			want: &Adapter{db: db},
 		},
 		{
 			name: "Test with txOptions",
-			args: args{
-				db: db,
-				options: []Option{
-					WithLog(logFunc),
-					WithTxOptions(txo),
-				},
+			options: []Option{
+				WithLog(logFunc),
+				WithTxOptions(txo),
 			},
-			want: &Adapter{db: db, txOptions: txo},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := NewAdapter(tt.args.db, tt.args.options...)
-			if got.db != tt.want.db {
-				t.Errorf("NewAdapter() = %v, want %v", got, tt.want)
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer MustClose(db, nil)
+
+			expectSetup(mock)
+
+			// the adapter speaks the PostgreSQL dialect on the given db
+			if err := NewAdapter(db, tt.options...).Setup(); err != nil {
+				t.Errorf("Setup() unexpected error %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdapter_WithTableAndSchema(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	mock.ExpectExec(makeMockFriendly(`CREATE TABLE IF NOT EXISTS "meta"."billing_migrations" (`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(`ALTER TABLE "meta"."billing_migrations" ADD COLUMN IF NOT EXISTS "checksum"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare(makeMockFriendly(`INSERT INTO "meta"."billing_migrations" ("name", "rollback", "checksum") VALUES ($1, $2, $3)`))
+	mock.ExpectPrepare(makeMockFriendly(`SELECT "name" FROM "meta"."billing_migrations" ORDER BY "name"`))
+	mock.ExpectPrepare(makeMockFriendly(`SELECT "name" FROM "meta"."billing_migrations" ORDER BY "created_at", "name"`))
+	mock.ExpectPrepare(makeMockFriendly(`SELECT "name", "checksum" FROM "meta"."billing_migrations" ORDER BY "name"`))
+	mock.ExpectPrepare(makeMockFriendly(`UPDATE "meta"."billing_migrations" SET "checksum" = $1 WHERE "name" = $2`))
+	mock.ExpectPrepare(makeMockFriendly(`SELECT "rollback" FROM "meta"."billing_migrations" WHERE "name" = $1`))
+	mock.ExpectPrepare(makeMockFriendly(`DELETE FROM "meta"."billing_migrations" WHERE "name" = $1`))
+	mock.ExpectQuery(makeMockFriendly(`SELECT "name" FROM "meta"."billing_migrations" ORDER BY "name"`)).WillReturnRows(
+		sqlmock.NewRows([]string{"name"}).AddRow("aaa"),
+	)
+	mock.ExpectQuery(makeMockFriendly(tryLock)).WithArgs(advisoryLockKey("meta.billing_migrations")).WillReturnRows(
+		sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true),
+	)
+
+	a := NewAdapter(db, sqldb.WithSchema("meta"), sqldb.WithTable("billing_migrations"))
+	if err := a.Setup(); err != nil {
+		t.Fatalf("Setup() unexpected error %v", err)
+	}
+	if _, err := a.List(); err != nil {
+		t.Errorf("List() unexpected error %v", err)
+	}
+	if err := a.Lock(context.Background()); err != nil {
+		t.Fatalf("Lock() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestNewAdapter_LockKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		// resolve is set if the schema of the table is resolved with current_schema()
+		resolve bool
+		want    int64
+	}{
+		{
+			name:    "default",
+			resolve: true,
+			want:    advisoryLockKey("public.migrations"),
+		},
+		{
+			name:    "with table",
+			options: []Option{sqldb.WithTable("billing")},
+			resolve: true,
+			want:    advisoryLockKey("public.billing"),
+		},
+		{
+			name:    "with schema",
+			options: []Option{sqldb.WithSchema("meta")},
+			want:    advisoryLockKey("meta.migrations"),
+		},
+		{
+			// the key follows the table, so naming the default schema takes the same lock
+			name:    "with the default schema",
+			options: []Option{sqldb.WithSchema("public")},
+			want:    advisoryLockKey("public.migrations"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer MustClose(db, nil)
+
+			if tt.resolve {
+				expectCurrentSchema(mock)
+			}
+			mock.ExpectQuery(makeMockFriendly(tryLock)).WithArgs(tt.want).WillReturnRows(
+				sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true),
+			)
+
+			if err := NewAdapter(db, tt.options...).Lock(context.Background()); err != nil {
+				t.Errorf("Lock() unexpected error %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
 			}
 		})
 	}
 }
+
+func TestAdapter_Lock_WithCurrentSchemaError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	mock.ExpectQuery(makeMockFriendly(currentSchema)).WillReturnError(errors.New("query error"))
+
+	a := NewAdapter(db)
+
+	wantErr := true
+	if err := a.Lock(context.Background()); (err != nil) != wantErr {
+		t.Errorf("Lock() error = %v, wantErr %v", err, wantErr)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestNewAdapter_Quoting(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	mock.ExpectExec(makeMockFriendly(`CREATE TABLE IF NOT EXISTS "odd""schema"."Migrations" (`)).WillReturnError(errors.New("test error"))
+
+	if err := NewAdapter(db, sqldb.WithSchema(`odd"schema`), sqldb.WithTable("Migrations")).Setup(); err == nil {
+		t.Errorf("Setup() error = nil, want the error of the quoted table")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
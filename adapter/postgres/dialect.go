@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mertenvg/migrate"
+
+	"github.com/mertenvg/migrate/adapter/sqldb"
+)
+
+// noTransaction matches the statements PostgreSQL refuses to run inside a transaction block
+var noTransaction = regexp.MustCompile(`(?is)^((CREATE\s+(UNIQUE\s+)?|DROP\s+)INDEX\s+CONCURRENTLY|REINDEX\b.*\bCONCURRENTLY|VACUUM|(CREATE|DROP)\s+(DATABASE|TABLESPACE|SUBSCRIPTION)|ALTER\s+SYSTEM)\b`)
+
+const tryLock = `
+	SELECT pg_try_advisory_lock($1)
+`
+
+const unlock = `
+	SELECT pg_advisory_unlock($1)
+`
+
+// currentSchema returns the schema a table is created in when its name is not qualified, the first existing schema in
+// the search path
+const currentSchema = `
+	SELECT current_schema()
+`
+
+// storeExists returns whether the table exists in the schema, or in the current schema if the schema is empty
+const storeExists = `
+	SELECT EXISTS (
+		SELECT 1 FROM pg_catalog.pg_tables
+		WHERE schemaname = COALESCE(NULLIF($1, ''), current_schema()) AND tablename = $2
+	)
+`
+
+// advisoryLockKey derives an advisory lock key from the schema qualified name of the migrations table
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// SQLSTATE codes returned when statement_timeout or lock_timeout is exceeded, or when a transaction conflicts with
+// another one
+const (
+	queryCanceled        = "57014"
+	lockNotAvailable     = "55P03"
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// sqlState returns the SQLSTATE code of the error if the driver exposes it, as pgx does
+func sqlState(err error) string {
+	var e interface{ SQLState() string }
+	if errors.As(err, &e) {
+		return e.SQLState()
+	}
+	return ""
+}
+
+// Dialect is the PostgreSQL sqldb.Dialect. It sets statement_timeout and lock_timeout for migration transactions, and
+// classifies lock timeouts, serialization failures and deadlocks as retryable.
+type Dialect struct{}
+
+var (
+	_ sqldb.Dialect           = Dialect{}
+	_ sqldb.Timeouts          = Dialect{}
+	_ migrate.RetryClassifier = Dialect{}
+)
+
+// Placeholder returns $n
+func (Dialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// Quote returns the identifier in double quotes
+func (Dialect) Quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// MigrationStore returns the migrations table, adding the checksum column to tables created before checksums were
+// recorded
+func (Dialect) MigrationStore(table string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			"name" VARCHAR(255) NOT NULL,
+			"created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+			"rollback" TEXT NULL,
+			"checksum" VARCHAR(64) NULL,
+			PRIMARY KEY ("name")
+		)`, table),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS "checksum" VARCHAR(64) NULL`, table),
+	}
+}
+
+// StoreExists looks the table up in the schema it is created in, the first existing schema in the search path if none
+// is given
+func (Dialect) StoreExists(ctx context.Context, db *sql.DB, schema, table string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, storeExists, schema, table).Scan(&exists)
+	return exists, err
+}
+
+// Locker returns a session level advisory lock keyed by the schema qualified table name
+func (Dialect) Locker() sqldb.Locker {
+	return advisoryLocker{}
+}
+
+// NoTransaction returns true for statements such as CREATE INDEX CONCURRENTLY and VACUUM
+func (Dialect) NoTransaction(q string) bool {
+	return noTransaction.MatchString(q)
+}
+
+type advisoryLocker struct{}
+
+// key returns the advisory lock key for the migrations table, resolving the schema it is created in if none was given
+func (advisoryLocker) key(ctx context.Context, conn *sql.Conn, schema, table string) (int64, error) {
+	if schema == "" {
+		if err := conn.QueryRowContext(ctx, currentSchema).Scan(&schema); err != nil {
+			return 0, fmt.Errorf("cannot resolve the schema of the migrations table: %w", err)
+		}
+	}
+	return advisoryLockKey(schema + "." + table), nil
+}
+
+func (l advisoryLocker) TryLock(ctx context.Context, conn *sql.Conn, schema, table string) (bool, error) {
+	key, err := l.key(ctx, conn, schema, table)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	err = conn.QueryRowContext(ctx, tryLock, key).Scan(&locked)
+	return locked, err
+}
+
+func (l advisoryLocker) Unlock(ctx context.Context, conn *sql.Conn, schema, table string) error {
+	key, err := l.key(ctx, conn, schema, table)
+	if err != nil {
+		return err
+	}
+	var unlocked bool
+	if err := conn.QueryRowContext(ctx, unlock, key).Scan(&unlocked); err != nil {
+		return err
+	}
+	if !unlocked {
+		return errors.New("lock was not held")
+	}
+	return nil
+}
+
+// SetTimeouts returns SET LOCAL statements for lock_timeout and statement_timeout, or SET statements for the session
+func (Dialect) SetTimeouts(statement, lock time.Duration, session bool) []string {
+	set := "SET LOCAL"
+	if session {
+		set = "SET"
+	}
+	var qs []string
+	if lock > 0 {
+		qs = append(qs, fmt.Sprintf("%s lock_timeout = %d", set, lock.Milliseconds()))
+	}
+	if statement > 0 {
+		qs = append(qs, fmt.Sprintf("%s statement_timeout = %d", set, statement.Milliseconds()))
+	}
+	return qs
+}
+
+// ResetTimeouts returns RESET statements for the lock_timeout and statement_timeout set for the session
+func (Dialect) ResetTimeouts(statement, lock time.Duration) []string {
+	var qs []string
+	if lock > 0 {
+		qs = append(qs, "RESET lock_timeout")
+	}
+	if statement > 0 {
+		qs = append(qs, "RESET statement_timeout")
+	}
+	return qs
+}
+
+// TimedOut returns whether the error is a statement cancelled by statement_timeout or by lock_timeout
+func (Dialect) TimedOut(err error) (statement, lock bool) {
+	code := sqlState(err)
+	return code == queryCanceled, code == lockNotAvailable
+}
+
+// Retryable returns true if the error is a lock timeout, serialization failure or deadlock, which could succeed if the
+// transaction is run again
+func (Dialect) Retryable(err error) bool {
+	switch sqlState(err) {
+	case lockNotAvailable, serializationFailure, deadlockDetected:
+		return true
+	}
+	return false
+}
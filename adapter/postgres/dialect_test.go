@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDialect_NoTransaction(t *testing.T) {
+	tests := []struct {
+		q    string
+		want bool
+	}{
+		{q: "CREATE INDEX CONCURRENTLY a_b ON a (b)", want: true},
+		{q: "create unique index concurrently a_b on a (b)", want: true},
+		{q: "DROP INDEX CONCURRENTLY a_b", want: true},
+		{q: "REINDEX (VERBOSE) INDEX CONCURRENTLY a_b", want: true},
+		{q: "VACUUM ANALYZE a", want: true},
+		{q: "CREATE DATABASE b", want: true},
+		{q: "ALTER SYSTEM SET work_mem = '64MB'", want: true},
+		{q: "CREATE INDEX a_b ON a (b)", want: false},
+		{q: "CREATE TABLE concurrently (id INT)", want: false},
+		{q: "INSERT INTO vacuum VALUES (1)", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			if got := (Dialect{}).NoTransaction(tt.q); got != tt.want {
+				t.Errorf("NoTransaction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_Quote(t *testing.T) {
+	if got, want := (Dialect{}).Quote(`odd"name`), `"odd""name"`; got != want {
+		t.Errorf("Quote() = %v, want %v", got, want)
+	}
+}
+
+func TestDialect_SetTimeouts(t *testing.T) {
+	tests := []struct {
+		name            string
+		statement, lock time.Duration
+		session         bool
+		want            []string
+	}{
+		{name: "none"},
+		{name: "statement", statement: 2 * time.Second, want: []string{"SET LOCAL statement_timeout = 2000"}},
+		{name: "lock", lock: time.Second, want: []string{"SET LOCAL lock_timeout = 1000"}},
+		{
+			name:      "both",
+			statement: 2 * time.Second,
+			lock:      time.Second,
+			want:      []string{"SET LOCAL lock_timeout = 1000", "SET LOCAL statement_timeout = 2000"},
+		},
+		{
+			name:      "both for the session",
+			statement: 2 * time.Second,
+			lock:      time.Second,
+			session:   true,
+			want:      []string{"SET lock_timeout = 1000", "SET statement_timeout = 2000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Dialect{}).SetTimeouts(tt.statement, tt.lock, tt.session); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SetTimeouts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_ResetTimeouts(t *testing.T) {
+	tests := []struct {
+		name            string
+		statement, lock time.Duration
+		want            []string
+	}{
+		{name: "none"},
+		{name: "statement", statement: 2 * time.Second, want: []string{"RESET statement_timeout"}},
+		{name: "both", statement: 2 * time.Second, lock: time.Second, want: []string{"RESET lock_timeout", "RESET statement_timeout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Dialect{}).ResetTimeouts(tt.statement, tt.lock); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResetTimeouts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialect_TimedOut(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		statement, lock bool
+	}{
+		{name: "statement timeout", err: SQLStateError(queryCanceled), statement: true},
+		{name: "lock timeout", err: SQLStateError(lockNotAvailable), lock: true},
+		{name: "deadlock", err: SQLStateError(deadlockDetected)},
+		{name: "no sqlstate", err: errors.New("connection reset")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, lock := (Dialect{}).TimedOut(tt.err)
+			if statement != tt.statement || lock != tt.lock {
+				t.Errorf("TimedOut() = %v, %v, want %v, %v", statement, lock, tt.statement, tt.lock)
+			}
+		})
+	}
+}
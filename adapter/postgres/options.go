@@ -1,15 +1,15 @@
 package postgres
 
-import "database/sql"
+import (
+	"database/sql"
+
+	"github.com/mertenvg/migrate/adapter/sqldb"
+)
 
 func WithLog(f LogFunc) Option {
-	return func(a *Adapter) {
-		a.log = f
-	}
+	return sqldb.WithLog(f)
 }
 
 func WithTxOptions(txOptions *sql.TxOptions) Option {
-	return func(a *Adapter) {
-		a.txOptions = txOptions
-	}
+	return sqldb.WithTxOptions(txOptions)
 }
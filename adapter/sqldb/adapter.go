@@ -0,0 +1,844 @@
+package sqldb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/mertenvg/migrate"
+	"github.com/mertenvg/migrate/pkg/checksum"
+	"github.com/mertenvg/migrate/pkg/reader"
+	"github.com/mertenvg/migrate/pkg/statements"
+)
+
+// lockPollInterval is how long Lock waits before trying to acquire a lock held by another session again
+const lockPollInterval = 100 * time.Millisecond
+
+// ErrNoTransaction is returned for a migration run in a transaction with a statement that cannot run in one. With the
+// files provider, such a migration starts with a "-- migrate:no-transaction" line.
+var ErrNoTransaction = errors.New("statement cannot run in a transaction")
+
+type LogFunc func(v ...any)
+
+// Progress is reported after each statement of a migration was executed
+type Progress struct {
+	// Migration is the name of the migration the statement belongs to
+	Migration string
+	// Direction the migration is run in
+	Direction migrate.Direction
+	// Statement is the ordinal of the statement in the migration, starting at 1
+	Statement int
+	// Total is the number of statements in the migration
+	Total int
+	// Duration is how long the statement took
+	Duration time.Duration
+	// Elapsed is how long the migration has been running for
+	Elapsed time.Duration
+	// RowsAffected by the statement, 0 if the driver does not report it
+	RowsAffected int64
+}
+
+// ProgressFunc receives the Progress of a migration. It is called synchronously, so it should return quickly.
+type ProgressFunc func(p Progress)
+
+type Option func(*Adapter)
+
+// queries are the statements keeping track of migrations, written in the Dialect
+type queries struct {
+	add              string
+	migrations       string
+	applied          string
+	checksums        string
+	updateChecksum   string
+	rollbackWithName string
+	removeWithName   string
+}
+
+// newQueries returns the queries for the migrations table, which must be quoted and may be schema qualified
+func newQueries(d Dialect, table string) queries {
+	name, rollback, sum := d.Quote("name"), d.Quote("rollback"), d.Quote("checksum")
+	return queries{
+		add:              fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES (%s, %s, %s)", table, name, rollback, sum, d.Placeholder(1), d.Placeholder(2), d.Placeholder(3)),
+		migrations:       fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", name, table, name),
+		applied:          fmt.Sprintf("SELECT %s FROM %s ORDER BY %s, %s", name, table, d.Quote("created_at"), name),
+		checksums:        fmt.Sprintf("SELECT %s, %s FROM %s ORDER BY %s", name, sum, table, name),
+		updateChecksum:   fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = %s", table, sum, d.Placeholder(1), name, d.Placeholder(2)),
+		rollbackWithName: fmt.Sprintf("SELECT %s FROM %s WHERE %s = %s", rollback, table, name, d.Placeholder(1)),
+		removeWithName:   fmt.Sprintf("DELETE FROM %s WHERE %s = %s", table, name, d.Placeholder(1)),
+	}
+}
+
+type Adapter struct {
+	db        *sql.DB
+	dialect   Dialect
+	log       LogFunc
+	logger    *slog.Logger
+	progress  ProgressFunc
+	txOptions *sql.TxOptions
+	stmts     statements.Statements
+	lockConn  *sql.Conn
+
+	schema string
+	table  string
+	// qualified is the quoted migrations table, qualified with its schema if WithSchema was given
+	qualified string
+	q         queries
+
+	lockTimeout      time.Duration
+	statementTimeout time.Duration
+
+	// conn is the connection the transaction, or a migration run without one, runs on, pinned so the Dialect's
+	// ConnHook applies to it
+	conn *sql.Conn
+	tx   *sql.Tx
+	// hooked is set when the Dialect's ConnHook changed conn, which must be checked before committing and restored
+	hooked bool
+	// timed is set when the Dialect's Timeouts were set on conn for a migration run without a transaction, which must
+	// be reset before conn is returned to the pool
+	timed bool
+	// ctx is the context passed to Begin, which the transactions begun by checkpoint run with as well
+	ctx context.Context
+	// recorded lists the migrations recorded in the current transaction, committed those committed by checkpoint
+	// since Begin
+	recorded  []string
+	committed []string
+}
+
+func MustClose(c io.Closer, log LogFunc) {
+	err := c.Close()
+	if err != nil && log != nil {
+		log("failed to close:", err)
+	}
+}
+
+// NewAdapter creates an Adapter for any database/sql driver, speaking the SQL of the given Dialect. Applied migrations
+// and their rollbacks are recorded in the "migrations" table unless WithTable or WithSchema say otherwise.
+//
+// A migration with a statement the Dialect says cannot run in a transaction fails with ErrNoTransaction when it runs in
+// one. Such a migration has to implement migrate.NonTransactional, so Migrate runs it without a transaction.
+//
+// A migration with a statement the Dialect's ImplicitCommitter says the database commits the transaction for cannot be
+// rolled back with the others. The migrations before it are committed first, and it is committed as soon as it was
+// recorded, so the migrations table always matches what was committed. A failure after such a commit is returned as a
+// *migrate.IncompleteError listing the migrations that were committed.
+func NewAdapter(db *sql.DB, dialect Dialect, options ...Option) *Adapter {
+	a := &Adapter{
+		db:      db,
+		dialect: dialect,
+		table:   "migrations",
+		log:     func(v ...any) {},
+		logger:  slog.New(slog.DiscardHandler),
+	}
+	for _, option := range options {
+		option(a)
+	}
+	a.qualified = dialect.Quote(a.table)
+	if a.schema != "" {
+		a.qualified = dialect.Quote(a.schema) + "." + a.qualified
+	}
+	a.q = newQueries(dialect, a.qualified)
+	return a
+}
+
+func (a *Adapter) Setup() error {
+	return a.SetupContext(context.Background())
+}
+
+func (a *Adapter) SetupContext(ctx context.Context) error {
+	ctx = orBackground(ctx)
+	for _, q := range a.dialect.MigrationStore(a.qualified) {
+		if _, err := a.db.ExecContext(ctx, q); err != nil {
+			return fmt.Errorf("sqldb.Adapter Setup failed: %w", err)
+		}
+	}
+	if err := a.prepare(ctx); err != nil {
+		return fmt.Errorf("sqldb.Adapter Setup failed: %w", err)
+	}
+	return nil
+}
+
+// InspectContext prepares the queries reading the migrations table if it exists, without creating it, returning false
+// if it does not
+func (a *Adapter) InspectContext(ctx context.Context) (bool, error) {
+	ctx = orBackground(ctx)
+	exists, err := a.dialect.StoreExists(ctx, a.db, a.schema, a.table)
+	if err != nil {
+		return false, fmt.Errorf("sqldb.Adapter Inspect failed: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+	if err := a.prepare(ctx); err != nil {
+		return false, fmt.Errorf("sqldb.Adapter Inspect failed: %w", err)
+	}
+	return true, nil
+}
+
+// prepare prepares the queries keeping track of migrations in the migrations table
+func (a *Adapter) prepare(ctx context.Context) error {
+	var err error
+	a.stmts, err = statements.PrepareContext(ctx, a.db, a.q.add, a.q.migrations, a.q.applied, a.q.checksums, a.q.updateChecksum, a.q.rollbackWithName, a.q.removeWithName)
+	return err
+}
+
+func (a *Adapter) List() ([]string, error) {
+	return a.ListContext(context.Background())
+}
+
+func (a *Adapter) ListContext(ctx context.Context) ([]string, error) {
+	names, err := a.list(ctx, a.q.migrations)
+	if err != nil {
+		return nil, fmt.Errorf("sqldb.Adapter List failed: %w", err)
+	}
+	return names, nil
+}
+
+// ListAppliedContext lists the applied migrations in the order they were applied, by name for those applied at the
+// same time
+func (a *Adapter) ListAppliedContext(ctx context.Context) ([]string, error) {
+	names, err := a.list(ctx, a.q.applied)
+	if err != nil {
+		return nil, fmt.Errorf("sqldb.Adapter ListApplied failed: %w", err)
+	}
+	return names, nil
+}
+
+// list returns the migration names selected by the prepared query
+func (a *Adapter) list(ctx context.Context, q string) ([]string, error) {
+	rows, err := a.stmts.Get(q).QueryContext(orBackground(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer MustClose(rows, a.log)
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (a *Adapter) Checksums() (map[string]string, error) {
+	return a.ChecksumsContext(context.Background())
+}
+
+func (a *Adapter) ChecksumsContext(ctx context.Context) (map[string]string, error) {
+	rows, err := a.stmts.Get(a.q.checksums).QueryContext(orBackground(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("sqldb.Adapter Checksums failed: %w", err)
+	}
+	defer MustClose(rows, a.log)
+
+	sums := make(map[string]string)
+	for rows.Next() {
+		var name string
+		var sum sql.NullString
+		if err := rows.Scan(&name, &sum); err != nil {
+			return nil, fmt.Errorf("sqldb.Adapter Checksums failed: %w", err)
+		}
+		sums[name] = sum.String
+	}
+
+	return sums, nil
+}
+
+func (a *Adapter) SetChecksum(name, sum string) error {
+	return a.SetChecksumContext(context.Background(), name, sum)
+}
+
+func (a *Adapter) SetChecksumContext(ctx context.Context, name, sum string) error {
+	if _, err := a.stmts.Get(a.q.updateChecksum).ExecContext(orBackground(ctx), sum, name); err != nil {
+		return fmt.Errorf("sqldb.Adapter SetChecksum failed for migration '%s': %w", name, err)
+	}
+	return nil
+}
+
+func (a *Adapter) RollbackSQL(name string) (string, error) {
+	return a.RollbackSQLContext(context.Background(), name)
+}
+
+func (a *Adapter) RollbackSQLContext(ctx context.Context, name string) (string, error) {
+	var rollback sql.NullString
+	err := a.stmts.Get(a.q.rollbackWithName).QueryRowContext(orBackground(ctx), name).Scan(&rollback)
+	if err != nil {
+		return "", fmt.Errorf("sqldb.Adapter RollbackSQL failed to get rollback sql for migration '%s': %w", name, err)
+	}
+	return rollback.String, nil
+}
+
+// Lock acquires the Dialect's migration lock on a connection of its own, waiting until it is released by any other
+// session or ctx is done. It does nothing if the Dialect has no Locker.
+func (a *Adapter) Lock(ctx context.Context) error {
+	l := a.dialect.Locker()
+	if l == nil {
+		return nil
+	}
+	if a.lockConn != nil {
+		return fmt.Errorf("sqldb.Adapter Lock failed: lock already held")
+	}
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("sqldb.Adapter Lock failed: %w", err)
+	}
+	for {
+		locked, err := l.TryLock(ctx, conn, a.schema, a.table)
+		if err != nil {
+			MustClose(conn, a.log)
+			return fmt.Errorf("sqldb.Adapter Lock failed: %w", err)
+		}
+		if locked {
+			a.lockConn = conn
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			MustClose(conn, a.log)
+			return fmt.Errorf("sqldb.Adapter Lock failed: %w", ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Unlock releases the lock acquired by Lock
+func (a *Adapter) Unlock() error {
+	l := a.dialect.Locker()
+	if l == nil {
+		return nil
+	}
+	if a.lockConn == nil {
+		return fmt.Errorf("sqldb.Adapter Unlock failed: no lock to release")
+	}
+	defer func() {
+		MustClose(a.lockConn, a.log)
+		a.lockConn = nil
+	}()
+	if err := l.Unlock(context.Background(), a.lockConn, a.schema, a.table); err != nil {
+		return fmt.Errorf("sqldb.Adapter Unlock failed: %w", err)
+	}
+	return nil
+}
+
+// SetStatementTimeout limits how long each statement may run, 0 removes the limit
+func (a *Adapter) SetStatementTimeout(d time.Duration) {
+	a.statementTimeout = d
+}
+
+// Retryable returns true if the Dialect implements migrate.RetryClassifier and says the error could succeed if the
+// transaction is run again
+func (a *Adapter) Retryable(err error) bool {
+	rc, ok := a.dialect.(migrate.RetryClassifier)
+	return ok && rc.Retryable(err)
+}
+
+// Begin starts a transaction on a connection of its own, letting the Dialect's ConnHook prepare the connection first
+func (a *Adapter) Begin(ctx context.Context) error {
+	if a.tx != nil {
+		return fmt.Errorf("sqldb.Adapter Begin failed: transaction already started")
+	}
+	a.ctx = orBackground(ctx)
+	a.recorded, a.committed = nil, nil
+	return a.begin()
+}
+
+// begin starts a transaction with the context passed to Begin
+func (a *Adapter) begin() error {
+	conn, err := a.db.Conn(a.ctx)
+	if err != nil {
+		return fmt.Errorf("sqldb.Adapter Begin failed: %w", err)
+	}
+	a.conn = conn
+	if h, ok := a.dialect.(ConnHook); ok {
+		if a.hooked, err = h.Prepare(a.ctx, conn); err != nil {
+			return a.release(fmt.Errorf("sqldb.Adapter Begin failed: %w", err))
+		}
+	}
+	tx, err := conn.BeginTx(a.ctx, a.txOptions)
+	if err != nil {
+		return a.release(fmt.Errorf("sqldb.Adapter Begin failed: %w", err))
+	}
+	if t, ok := a.dialect.(Timeouts); ok {
+		for _, q := range t.SetTimeouts(a.statementTimeout, a.lockTimeout, false) {
+			if _, err := tx.ExecContext(a.ctx, q); err != nil {
+				if rbErr := tx.Rollback(); rbErr != nil {
+					a.log("failed to roll back:", rbErr)
+				}
+				return a.release(fmt.Errorf("sqldb.Adapter Begin failed to set timeouts: %w", err))
+			}
+		}
+	}
+	a.tx = tx
+	return nil
+}
+
+func (a *Adapter) Commit() error {
+	return a.incomplete(a.commit())
+}
+
+// commit commits the current transaction once the Dialect's ConnHook checked it, if it changed the connection
+func (a *Adapter) commit() error {
+	if a.tx == nil {
+		return fmt.Errorf("sqldb.Adapter Commit failed: no transaction to commit")
+	}
+	tx := a.tx
+	a.tx = nil
+
+	if a.hooked {
+		if err := a.dialect.(ConnHook).Check(a.ctx, tx); err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				a.log("failed to roll back:", rbErr)
+			}
+			return a.release(fmt.Errorf("sqldb.Adapter Commit failed: %w", err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return a.release(fmt.Errorf("sqldb.Adapter Commit failed: %w", err))
+	}
+	return a.release(nil)
+}
+
+func (a *Adapter) Rollback() error {
+	if a.tx == nil {
+		return fmt.Errorf("sqldb.Adapter Rollback failed: no transaction to commit")
+	}
+	tx := a.tx
+	a.tx = nil
+
+	if err := tx.Rollback(); err != nil {
+		return a.release(fmt.Errorf("sqldb.Adapter Rollback failed: %w", err))
+	}
+	return a.release(nil)
+}
+
+// release resets the Dialect's Timeouts set for the session and lets the Dialect's ConnHook restore the connection, if
+// they changed it, and returns the connection to the pool, adding any error doing so to err
+func (a *Adapter) release(err error) error {
+	conn := a.conn
+	a.conn = nil
+	if conn == nil {
+		return err
+	}
+	defer MustClose(conn, a.log)
+
+	if a.timed {
+		a.timed = false
+		for _, q := range a.dialect.(Timeouts).ResetTimeouts(a.statementTimeout, a.lockTimeout) {
+			if _, tErr := conn.ExecContext(context.Background(), q); tErr != nil {
+				tErr = fmt.Errorf("sqldb.Adapter failed to reset timeouts: %w", tErr)
+				if err == nil {
+					err = tErr
+				} else {
+					a.log(tErr)
+				}
+			}
+		}
+	}
+
+	if a.hooked {
+		a.hooked = false
+		if hErr := a.dialect.(ConnHook).Restore(context.Background(), conn); hErr != nil {
+			hErr = fmt.Errorf("sqldb.Adapter failed to restore the connection: %w", hErr)
+			if err == nil {
+				return hErr
+			}
+			a.log(hErr)
+		}
+	}
+	return err
+}
+
+// checkpoint commits the current transaction, if there is one, and begins a new one in its place. The new transaction
+// is begun with the context passed to Begin, as the context of the migration is done once the migration is.
+func (a *Adapter) checkpoint() error {
+	if a.tx == nil {
+		return nil
+	}
+	if err := a.commit(); err != nil {
+		return err
+	}
+	a.committed = append(a.committed, a.recorded...)
+	a.recorded = nil
+	return a.begin()
+}
+
+// record notes the migration was recorded in the current transaction, if there is one
+func (a *Adapter) record(name string) {
+	if a.tx != nil {
+		a.recorded = append(a.recorded, name)
+	}
+}
+
+// incomplete wraps err in a *migrate.IncompleteError if checkpoint committed migrations since Begin, which rolling
+// back the transaction no longer undoes
+func (a *Adapter) incomplete(err error) error {
+	if err == nil || len(a.committed) == 0 {
+		return err
+	}
+	return &migrate.IncompleteError{Committed: slices.Clone(a.committed), Err: err}
+}
+
+// exec runs the query in the current transaction, on the pinned connection of a migration run without one, or
+// directly on the db
+func (a *Adapter) exec(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	switch {
+	case a.tx != nil:
+		return a.tx.ExecContext(ctx, q, args...)
+	case a.conn != nil:
+		return a.conn.ExecContext(ctx, q, args...)
+	}
+	return a.db.ExecContext(ctx, q, args...)
+}
+
+// execStmt runs the prepared statement for the query in the current transaction, or runs the query on the pinned
+// connection of a migration run without one, as the prepared statement could pick any connection of the pool
+func (a *Adapter) execStmt(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	if a.tx == nil && a.conn != nil {
+		return a.conn.ExecContext(ctx, q, args...)
+	}
+	return a.stmt(ctx, q).ExecContext(ctx, args...)
+}
+
+// pin takes a connection of its own for a migration run without a transaction, letting the Dialect's ConnHook prepare
+// it and setting the Dialect's Timeouts on it for the session, as they are for a transaction. The connection is
+// released with release.
+func (a *Adapter) pin(ctx context.Context) error {
+	conn, err := a.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection: %w", err)
+	}
+	a.conn = conn
+	if h, ok := a.dialect.(ConnHook); ok {
+		if a.hooked, err = h.Prepare(ctx, conn); err != nil {
+			return a.release(err)
+		}
+	}
+	if t, ok := a.dialect.(Timeouts); ok {
+		qs := t.SetTimeouts(a.statementTimeout, a.lockTimeout, true)
+		a.timed = len(qs) > 0
+		for _, q := range qs {
+			if _, err := conn.ExecContext(ctx, q); err != nil {
+				return a.release(fmt.Errorf("failed to set timeouts: %w", err))
+			}
+		}
+	}
+	return nil
+}
+
+// stmt returns the prepared statement for the query bound to the current transaction, if there is one
+func (a *Adapter) stmt(ctx context.Context, q string) *sql.Stmt {
+	if a.tx == nil {
+		return a.stmts.Get(q)
+	}
+	return a.tx.StmtContext(ctx, a.stmts.Get(q))
+}
+
+// execStatement executes a single statement of a migration, limited by the statement timeout if there is one
+func (a *Adapter) execStatement(ctx context.Context, q string) (sql.Result, error) {
+	stmtCtx := ctx
+	if a.statementTimeout > 0 {
+		var cancel context.CancelFunc
+		stmtCtx, cancel = context.WithTimeout(ctx, a.statementTimeout)
+		defer cancel()
+	}
+
+	res, err := a.exec(stmtCtx, q)
+	if err == nil {
+		return res, nil
+	}
+	var statement, lock bool
+	if t, ok := a.dialect.(Timeouts); ok {
+		statement, lock = t.TimedOut(err)
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// the migration's own deadline passed, the caller knows its timeout
+		return nil, &migrate.TimeoutError{Statement: q, Err: err}
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("failed to execute query '%s': %w", q, err)
+	case errors.Is(stmtCtx.Err(), context.DeadlineExceeded), statement:
+		return nil, &migrate.TimeoutError{Statement: q, Timeout: a.statementTimeout, Err: err}
+	case lock:
+		return nil, &migrate.TimeoutError{Statement: q, Timeout: a.lockTimeout, Err: err}
+	}
+	return nil, fmt.Errorf("failed to execute query '%s': %w", q, err)
+}
+
+// read returns the statements of a migration script, split by the Dialect's Splitter if it has one
+func (a *Adapter) read(src io.Reader) ([]string, error) {
+	r := reader.NewSQLReader(src)
+	next := r.Next
+	if s, ok := a.dialect.(Splitter); ok {
+		next = func() (string, error) { return s.Next(r) }
+	}
+	var queries []string
+	for {
+		q, err := next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get query: %w", err)
+		}
+		if q == "" {
+			return queries, nil
+		}
+		if reader.TransactionControl(q) {
+			continue
+		}
+		queries = append(queries, q)
+	}
+}
+
+// implicitCommit returns true if the Dialect's ImplicitCommitter says the database commits the current transaction
+// before running the statement
+func (a *Adapter) implicitCommit(q string) bool {
+	c, ok := a.dialect.(ImplicitCommitter)
+	return ok && c.ImplicitCommit(q)
+}
+
+// run executes the statements of a migration, logging and reporting progress for each one, and then record, which
+// updates the migrations table. A statement that cannot run in a transaction fails the migration if there is one,
+// rather than committing the work of the migrations before it behind Migrate's back. If a statement causes an implicit
+// commit instead, the migrations before are committed first, and the migration is committed as soon as it was
+// recorded, so a failure leaves the migrations table matching what was committed.
+func (a *Adapter) run(ctx context.Context, name string, direction migrate.Direction, queries []string, record func() error) (err error) {
+	if a.tx != nil && slices.ContainsFunc(queries, a.dialect.NoTransaction) {
+		return fmt.Errorf("%w, the migration has to implement migrate.NonTransactional", ErrNoTransaction)
+	}
+	if a.tx == nil {
+		if err := a.pin(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			err = a.release(err)
+		}()
+	}
+	implicit := slices.ContainsFunc(queries, a.implicitCommit)
+	if implicit && a.tx != nil {
+		a.log("migration", name, "causes an implicit commit and cannot be rolled back")
+		a.logger.WarnContext(ctx, "migration causes an implicit commit and cannot be rolled back", "migration", name)
+		if err := a.checkpoint(); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	var rows int64
+	for i, q := range queries {
+		stmtStart := time.Now()
+		res, err := a.execStatement(ctx, q)
+		if err != nil {
+			a.logger.LogAttrs(ctx, slog.LevelError, "statement failed",
+				slog.String("migration", name),
+				slog.String("direction", string(direction)),
+				slog.Int("statement", i+1),
+				slog.Duration("duration", time.Since(stmtStart)),
+				slog.Any("error", err),
+			)
+			if implicit {
+				a.logger.ErrorContext(ctx, "migration failed after an implicit commit, its earlier statements may have been committed", "migration", name)
+			}
+			return err
+		}
+		// not every driver reports rows affected for every statement, such as DDL
+		affected, _ := res.RowsAffected()
+		rows += affected
+		a.logger.LogAttrs(ctx, slog.LevelDebug, "statement executed",
+			slog.String("migration", name),
+			slog.String("direction", string(direction)),
+			slog.Int("statement", i+1),
+			slog.Duration("duration", time.Since(stmtStart)),
+			slog.Int64("rows_affected", affected),
+		)
+		if a.progress != nil {
+			a.progress(Progress{
+				Migration:    name,
+				Direction:    direction,
+				Statement:    i + 1,
+				Total:        len(queries),
+				Duration:     time.Since(stmtStart),
+				Elapsed:      time.Since(start),
+				RowsAffected: affected,
+			})
+		}
+	}
+	a.logger.LogAttrs(ctx, slog.LevelInfo, "migration statements executed",
+		slog.String("migration", name),
+		slog.String("direction", string(direction)),
+		slog.Int("statements", len(queries)),
+		slog.Duration("duration", time.Since(start)),
+		slog.Int64("rows_affected", rows),
+	)
+
+	if a.tx == nil && a.hooked {
+		if err := a.dialect.(ConnHook).Check(ctx, a.conn); err != nil {
+			return err
+		}
+	}
+	if err := record(); err != nil {
+		return err
+	}
+	a.record(name)
+	if implicit {
+		return a.checkpoint()
+	}
+	return nil
+}
+
+func (a *Adapter) Up(name string, up, down io.Reader) error {
+	return a.UpContext(context.Background(), name, up, down)
+}
+
+func (a *Adapter) UpContext(ctx context.Context, name string, up, down io.Reader) error {
+	a.log("Applying migration", name)
+	a.logger.InfoContext(orBackground(ctx), "applying migration", "migration", name)
+
+	ctx = orBackground(ctx)
+	upSum := checksum.NewReader(up)
+	queries, err := a.read(upSum)
+	if err != nil {
+		return fmt.Errorf("sqldb.Adapter Up error for migration '%s': %w", name, err)
+	}
+	sum, err := upSum.Drain()
+	if err != nil {
+		return fmt.Errorf("sqldb.Adapter Up failed to read up file for migration '%s': %w", name, err)
+	}
+
+	var downData []byte
+	if down != nil {
+		downData, err = io.ReadAll(down)
+		if err != nil {
+			return fmt.Errorf("sqldb.Adapter Up failed to read down file for migration '%s': %w", name, err)
+		}
+	}
+
+	err = a.run(ctx, name, migrate.DirectionUp, queries, func() error {
+		if _, err := a.execStmt(ctx, a.q.add, name, string(downData), sum); err != nil {
+			return fmt.Errorf("failed to register migration: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return a.incomplete(fmt.Errorf("sqldb.Adapter Up error for migration '%s': %w", name, err))
+	}
+	return nil
+}
+
+// UpFunc applies a migration written in Go by calling up with the current transaction, or a transaction of its own
+// if there is none. Implicit commits caused by up are not detected.
+func (a *Adapter) UpFunc(ctx context.Context, name string, up func(context.Context, *sql.Tx) error) error {
+	a.log("Applying migration", name)
+
+	if up == nil {
+		return fmt.Errorf("sqldb.Adapter UpFunc error for migration '%s': no up func", name)
+	}
+	ctx = orBackground(ctx)
+	err := a.withTx(ctx, func(tx *sql.Tx) error {
+		if err := up(ctx, tx); err != nil {
+			return fmt.Errorf("sqldb.Adapter UpFunc error for migration '%s': %w", name, err)
+		}
+		if _, err := tx.StmtContext(ctx, a.stmts.Get(a.q.add)).ExecContext(ctx, name, "", ""); err != nil {
+			return fmt.Errorf("sqldb.Adapter UpFunc failed to register migration '%s': %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return a.incomplete(err)
+	}
+	a.record(name)
+	return nil
+}
+
+// DownFunc takes down a migration written in Go by calling down, if it isn't nil, with the current transaction, or a
+// transaction of its own if there is none. Implicit commits caused by down are not detected.
+func (a *Adapter) DownFunc(ctx context.Context, name string, down func(context.Context, *sql.Tx) error) error {
+	a.log("Taking down migration", name)
+
+	ctx = orBackground(ctx)
+	err := a.withTx(ctx, func(tx *sql.Tx) error {
+		if down != nil {
+			if err := down(ctx, tx); err != nil {
+				return fmt.Errorf("sqldb.Adapter DownFunc error for migration '%s': %w", name, err)
+			}
+		}
+		if _, err := tx.StmtContext(ctx, a.stmts.Get(a.q.removeWithName)).ExecContext(ctx, name); err != nil {
+			return fmt.Errorf("sqldb.Adapter DownFunc failed to remove migration '%s': %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return a.incomplete(err)
+	}
+	a.record(name)
+	return nil
+}
+
+// withTx calls f with the current transaction, or with a transaction of its own that is committed if f succeeds. The
+// transaction of its own is begun like the one of Begin, so the Dialect's ConnHook and timeouts apply to it.
+func (a *Adapter) withTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	if a.tx != nil {
+		return f(a.tx)
+	}
+	a.ctx = ctx
+	if err := a.begin(); err != nil {
+		return err
+	}
+	if err := f(a.tx); err != nil {
+		if rbErr := a.Rollback(); rbErr != nil {
+			a.log("failed to roll back:", rbErr)
+		}
+		return err
+	}
+	return a.commit()
+}
+
+func (a *Adapter) Down(name string) error {
+	return a.DownContext(context.Background(), name)
+}
+
+func (a *Adapter) DownContext(ctx context.Context, name string) error {
+	a.log("Taking down migration", name)
+	a.logger.InfoContext(orBackground(ctx), "taking down migration", "migration", name)
+
+	ctx = orBackground(ctx)
+	var rollback sql.NullString
+	err := a.stmt(ctx, a.q.rollbackWithName).QueryRowContext(ctx, name).Scan(&rollback)
+	if err != nil {
+		return fmt.Errorf("sqldb.Adapter Down failed to get rollback sql: %w", err)
+	}
+
+	if rollback.String == "" {
+		a.log("migration", name, "cannot be taken down because it does not have a rollback")
+		a.logger.WarnContext(ctx, "migration cannot be taken down because it does not have a rollback", "migration", name)
+		return nil
+	}
+
+	queries, err := a.read(bytes.NewBufferString(rollback.String))
+	if err != nil {
+		return fmt.Errorf("sqldb.Adapter Down error for migration '%s': %w", name, err)
+	}
+	err = a.run(ctx, name, migrate.DirectionDown, queries, func() error {
+		if _, err := a.execStmt(ctx, a.q.removeWithName, name); err != nil {
+			return fmt.Errorf("failed to remove migration: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return a.incomplete(fmt.Errorf("sqldb.Adapter Down error for migration '%s': %w", name, err))
+	}
+	return nil
+}
+
+// orBackground returns ctx, or context.Background() if ctx is nil
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
@@ -0,0 +1,397 @@
+package sqldb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/mertenvg/migrate"
+	"github.com/mertenvg/migrate/pkg/checksum"
+)
+
+var (
+	_ migrate.Adapter               = (*Adapter)(nil)
+	_ migrate.ContextAdapter        = (*Adapter)(nil)
+	_ migrate.RollbackReader        = (*Adapter)(nil)
+	_ migrate.Checksummer           = (*Adapter)(nil)
+	_ migrate.ContextRollbackReader = (*Adapter)(nil)
+	_ migrate.ContextChecksummer    = (*Adapter)(nil)
+	_ migrate.FuncAdapter           = (*Adapter)(nil)
+	_ migrate.Locker                = (*Adapter)(nil)
+	_ migrate.AppliedLister         = (*Adapter)(nil)
+	_ migrate.Inspector             = (*Adapter)(nil)
+)
+
+// MockDialect speaks a made up SQL with ? placeholders and [bracketed] identifiers
+type MockDialect struct {
+	locker Locker
+}
+
+func (MockDialect) Placeholder(n int) string {
+	return "?"
+}
+
+func (MockDialect) Quote(identifier string) string {
+	return "[" + identifier + "]"
+}
+
+func (MockDialect) MigrationStore(table string) []string {
+	return []string{"CREATE TABLE " + table + " ([name] TEXT, [created_at] TEXT, [rollback] TEXT, [checksum] TEXT)"}
+}
+
+func (MockDialect) StoreExists(ctx context.Context, db *sql.DB, schema, table string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT exists(?)", table).Scan(&exists)
+	return exists, err
+}
+
+func (d MockDialect) Locker() Locker {
+	return d.locker
+}
+
+func (MockDialect) NoTransaction(q string) bool {
+	return strings.HasPrefix(q, "VACUUM")
+}
+
+// MockLocker locks with the made up lock and unlock functions
+type MockLocker struct{}
+
+func (MockLocker) TryLock(ctx context.Context, conn *sql.Conn, schema, table string) (bool, error) {
+	var locked bool
+	err := conn.QueryRowContext(ctx, "SELECT lock(?)", table).Scan(&locked)
+	return locked, err
+}
+
+func (MockLocker) Unlock(ctx context.Context, conn *sql.Conn, schema, table string) error {
+	_, err := conn.ExecContext(ctx, "SELECT unlock(?)", table)
+	return err
+}
+
+var matchWhitespace = regexp.MustCompile("\\s+")
+
+func makeMockFriendly(s string) string {
+	return regexp.QuoteMeta(strings.TrimSpace(matchWhitespace.ReplaceAllString(s, " ")))
+}
+
+func mustSum(s string) string {
+	sum, _ := checksum.Sum(bytes.NewBufferString(s))
+	return sum
+}
+
+func expectSetup(mock sqlmock.Sqlmock, d Dialect, table string) queries {
+	q := newQueries(d, table)
+	for _, ddl := range d.MigrationStore(table) {
+		mock.ExpectExec(makeMockFriendly(ddl)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectPrepare(makeMockFriendly(q.add))
+	mock.ExpectPrepare(makeMockFriendly(q.migrations))
+	mock.ExpectPrepare(makeMockFriendly(q.applied))
+	mock.ExpectPrepare(makeMockFriendly(q.checksums))
+	mock.ExpectPrepare(makeMockFriendly(q.updateChecksum))
+	mock.ExpectPrepare(makeMockFriendly(q.rollbackWithName))
+	mock.ExpectPrepare(makeMockFriendly(q.removeWithName))
+	return q
+}
+
+func newAdapter(t *testing.T, d Dialect, options ...Option) (*Adapter, sqlmock.Sqlmock, queries) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() { MustClose(db, nil) })
+
+	a := NewAdapter(db, d, options...)
+	q := expectSetup(mock, d, a.qualified)
+	if err := a.Setup(); err != nil {
+		t.Fatalf("Setup() unexpected error %v", err)
+	}
+	return a, mock, q
+}
+
+func Test_newQueries(t *testing.T) {
+	got := newQueries(MockDialect{}, "[schema_history]")
+	want := queries{
+		add:              "INSERT INTO [schema_history] ([name], [rollback], [checksum]) VALUES (?, ?, ?)",
+		migrations:       "SELECT [name] FROM [schema_history] ORDER BY [name]",
+		applied:          "SELECT [name] FROM [schema_history] ORDER BY [created_at], [name]",
+		checksums:        "SELECT [name], [checksum] FROM [schema_history] ORDER BY [name]",
+		updateChecksum:   "UPDATE [schema_history] SET [checksum] = ? WHERE [name] = ?",
+		rollbackWithName: "SELECT [rollback] FROM [schema_history] WHERE [name] = ?",
+		removeWithName:   "DELETE FROM [schema_history] WHERE [name] = ?",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newQueries() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAdapter_Setup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db, nil)
+
+	mock.ExpectExec(makeMockFriendly("CREATE TABLE [migrations]")).WillReturnError(errors.New("permission denied"))
+
+	a := NewAdapter(db, MockDialect{})
+	if err := a.Setup(); err == nil {
+		t.Errorf("Setup() error = %v, wantErr %v", err, true)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_InspectContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		exists  bool
+		err     error
+		want    bool
+		wantErr bool
+	}{
+		{name: "missing", exists: false, want: false},
+		{name: "exists", exists: true, want: true},
+		{name: "error", err: errors.New("permission denied"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer MustClose(db, nil)
+
+			q := newQueries(MockDialect{}, "[migrations]")
+			exists := mock.ExpectQuery(makeMockFriendly("SELECT exists(?)")).WithArgs("migrations")
+			if tt.err != nil {
+				exists.WillReturnError(tt.err)
+			} else {
+				exists.WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(tt.exists))
+			}
+			if tt.exists {
+				mock.ExpectPrepare(makeMockFriendly(q.add))
+				mock.ExpectPrepare(makeMockFriendly(q.migrations))
+				mock.ExpectPrepare(makeMockFriendly(q.applied))
+				mock.ExpectPrepare(makeMockFriendly(q.checksums))
+				mock.ExpectPrepare(makeMockFriendly(q.updateChecksum))
+				mock.ExpectPrepare(makeMockFriendly(q.rollbackWithName))
+				mock.ExpectPrepare(makeMockFriendly(q.removeWithName))
+			}
+
+			got, err := NewAdapter(db, MockDialect{}).InspectContext(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InspectContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("InspectContext() = %v, want %v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdapter_ListAndChecksums(t *testing.T) {
+	a, mock, q := newAdapter(t, MockDialect{}, WithTable("schema_history"))
+	mock.ExpectQuery(makeMockFriendly(q.migrations)).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("aaa").AddRow("bbb"))
+	mock.ExpectQuery(makeMockFriendly(q.applied)).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("bbb").AddRow("aaa"))
+	mock.ExpectQuery(makeMockFriendly(q.checksums)).WillReturnRows(sqlmock.NewRows([]string{"name", "checksum"}).AddRow("aaa", "abc").AddRow("bbb", nil))
+	mock.ExpectExec(makeMockFriendly(q.updateChecksum)).WithArgs("def", "bbb").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	names, err := a.List()
+	if err != nil {
+		t.Fatalf("List() unexpected error %v", err)
+	}
+	if want := []string{"aaa", "bbb"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List() = %v, want %v", names, want)
+	}
+	applied, err := a.ListAppliedContext(context.Background())
+	if err != nil {
+		t.Fatalf("ListAppliedContext() unexpected error %v", err)
+	}
+	if want := []string{"bbb", "aaa"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("ListAppliedContext() = %v, want %v", applied, want)
+	}
+	sums, err := a.Checksums()
+	if err != nil {
+		t.Fatalf("Checksums() unexpected error %v", err)
+	}
+	if want := map[string]string{"aaa": "abc", "bbb": ""}; !reflect.DeepEqual(sums, want) {
+		t.Errorf("Checksums() = %v, want %v", sums, want)
+	}
+	if err := a.SetChecksum("bbb", "def"); err != nil {
+		t.Errorf("SetChecksum() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up(t *testing.T) {
+	tests := []struct {
+		name    string
+		up      string
+		expect  func(mock sqlmock.Sqlmock, q queries, up string)
+		wantErr bool
+	}{
+		{
+			name: "in the transaction",
+			up:   "BEGIN;\nINSERT INTO a VALUES (1);\nCOMMIT;",
+			expect: func(mock sqlmock.Sqlmock, q queries, up string) {
+				mock.ExpectExec(makeMockFriendly("INSERT INTO a VALUES (1)")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(makeMockFriendly(q.add)).WithArgs("aaa", "DELETE FROM a;", mustSum(up)).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:    "cannot run in the transaction",
+			up:      "INSERT INTO a VALUES (1);\nVACUUM a;",
+			expect:  func(mock sqlmock.Sqlmock, q queries, up string) {},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, mock, q := newAdapter(t, MockDialect{})
+			mock.ExpectBegin()
+			tt.expect(mock, q, tt.up)
+			mock.ExpectCommit()
+
+			if err := a.Begin(context.Background()); err != nil {
+				t.Fatalf("Begin() unexpected error %v", err)
+			}
+			err := a.Up("aaa", bytes.NewBufferString(tt.up), bytes.NewBufferString("DELETE FROM a;"))
+			if tt.wantErr && !errors.Is(err, ErrNoTransaction) || !tt.wantErr && err != nil {
+				t.Errorf("Up() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := a.Commit(); err != nil {
+				t.Errorf("Commit() unexpected error %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdapter_Up_WithoutTransaction(t *testing.T) {
+	a, mock, q := newAdapter(t, MockDialect{})
+	up := "INSERT INTO a VALUES (1);\nVACUUM a;"
+	mock.ExpectExec(makeMockFriendly("INSERT INTO a VALUES (1)")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(makeMockFriendly("VACUUM a")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(makeMockFriendly(q.add)).WithArgs("aaa", "DELETE FROM a;", mustSum(up)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := a.Up("aaa", bytes.NewBufferString(up), bytes.NewBufferString("DELETE FROM a;")); err != nil {
+		t.Errorf("Up() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Down(t *testing.T) {
+	tests := []struct {
+		name     string
+		rollback any
+		expect   func(mock sqlmock.Sqlmock, q queries)
+	}{
+		{
+			name:     "with rollback",
+			rollback: "DELETE FROM a;",
+			expect: func(mock sqlmock.Sqlmock, q queries) {
+				mock.ExpectExec(makeMockFriendly("DELETE FROM a")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(makeMockFriendly(q.removeWithName)).WithArgs("aaa").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:     "without rollback",
+			rollback: nil,
+			expect:   func(mock sqlmock.Sqlmock, q queries) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, mock, q := newAdapter(t, MockDialect{})
+			mock.ExpectBegin()
+			mock.ExpectQuery(makeMockFriendly(q.rollbackWithName)).WithArgs("aaa").WillReturnRows(sqlmock.NewRows([]string{"rollback"}).AddRow(tt.rollback))
+			tt.expect(mock, q)
+			mock.ExpectCommit()
+
+			if err := a.Begin(context.Background()); err != nil {
+				t.Fatalf("Begin() unexpected error %v", err)
+			}
+			if err := a.Down("aaa"); err != nil {
+				t.Errorf("Down() unexpected error %v", err)
+			}
+			if err := a.Commit(); err != nil {
+				t.Errorf("Commit() unexpected error %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAdapter_UpFunc(t *testing.T) {
+	a, mock, q := newAdapter(t, MockDialect{})
+	mock.ExpectBegin()
+	mock.ExpectExec(makeMockFriendly("UPDATE a SET b = 1")).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(makeMockFriendly(q.add)).WithArgs("aaa", "", "").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := a.UpFunc(context.Background(), "aaa", func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE a SET b = 1")
+		return err
+	})
+	if err != nil {
+		t.Errorf("UpFunc() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Lock(t *testing.T) {
+	a, mock, _ := newAdapter(t, MockDialect{locker: MockLocker{}})
+	mock.ExpectQuery(makeMockFriendly("SELECT lock(?)")).WithArgs("migrations").WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectQuery(makeMockFriendly("SELECT lock(?)")).WithArgs("migrations").WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec(makeMockFriendly("SELECT unlock(?)")).WithArgs("migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := a.Lock(context.Background()); err != nil {
+		t.Fatalf("Lock() unexpected error %v", err)
+	}
+	if err := a.Lock(context.Background()); err == nil {
+		t.Errorf("Lock() while holding the lock error = %v, wantErr %v", err, true)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Errorf("Unlock() unexpected error %v", err)
+	}
+	if err := a.Unlock(); err == nil {
+		t.Errorf("Unlock() without the lock error = %v, wantErr %v", err, true)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Lock_WithoutLocker(t *testing.T) {
+	a, mock, _ := newAdapter(t, MockDialect{})
+	if err := a.Lock(context.Background()); err != nil {
+		t.Errorf("Lock() unexpected error %v", err)
+	}
+	if err := a.Unlock(); err != nil {
+		t.Errorf("Unlock() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/mertenvg/migrate/pkg/reader"
+)
+
+// Dialect describes the SQL spoken by a database, so Adapter can keep track of migrations in it. A Dialect can
+// implement Splitter, ImplicitCommitter, ConnHook, Timeouts and migrate.RetryClassifier for the quirks and features of
+// its database.
+type Dialect interface {
+	// Placeholder returns the placeholder for the nth argument of a query, counting from 1, such as ? or $1
+	Placeholder(n int) string
+	// Quote returns the identifier quoted for use in a query
+	Quote(identifier string) string
+	// MigrationStore returns the statements creating the migrations table, if it does not exist yet, with the columns
+	// name, created_at, rollback and checksum. The table name is quoted already. The statements run in order on Setup.
+	MigrationStore(table string) []string
+	// StoreExists returns true if the migrations table exists, without creating it. The names are not quoted, and the
+	// schema is empty unless WithSchema was given, in which case the table is looked up where MigrationStore creates it.
+	StoreExists(ctx context.Context, db *sql.DB, schema, table string) (bool, error)
+	// Locker returns how migrators are stopped from running at the same time, or nil if the database cannot lock
+	Locker() Locker
+	// NoTransaction returns true if the statement cannot run inside a transaction. Adapter fails a migration with such
+	// a statement with ErrNoTransaction when it runs in a transaction.
+	NoTransaction(q string) bool
+}
+
+// Locker acquires and releases the migration lock on a connection of its own. The schema is empty unless WithSchema was
+// given, in which case the Locker has to resolve the schema the table is created in, so migrators share the lock
+// whether or not they name the default schema.
+type Locker interface {
+	// TryLock tries to acquire the lock for the migrations table once, returning false if another session holds it
+	TryLock(ctx context.Context, conn *sql.Conn, schema, table string) (bool, error)
+	// Unlock releases the lock acquired by TryLock on the same connection
+	Unlock(ctx context.Context, conn *sql.Conn, schema, table string) error
+}
+
+// Splitter can optionally be implemented by a Dialect with statements reader.SQLReader splits in the wrong place, such
+// as the semicolons in the body of a SQLite trigger
+type Splitter interface {
+	// Next returns the next statement read from r, or "" once r is done
+	Next(r *reader.SQLReader) (string, error)
+}
+
+// ImplicitCommitter can optionally be implemented by a Dialect for a database that commits the current transaction
+// before running some statements, such as DDL in MySQL
+type ImplicitCommitter interface {
+	// ImplicitCommit returns true if the database commits the current transaction before running the statement
+	ImplicitCommit(q string) bool
+}
+
+// ConnHook can optionally be implemented by a Dialect that changes the settings of the connection a migration runs on,
+// such as switching off foreign key enforcement in SQLite. The hook runs around each transaction, and around each
+// migration run without one on a connection of its own.
+type ConnHook interface {
+	// Prepare runs on the connection before the transaction is begun on it, or before the migration runs on it,
+	// returning true if it changed the connection. Check and Restore only run if it did.
+	Prepare(ctx context.Context, conn *sql.Conn) (bool, error)
+	// Check runs in the transaction before it is committed, which is rolled back instead if Check fails. Without a
+	// transaction it runs on the connection once the statements of the migration ran, failing the migration before it
+	// is recorded.
+	Check(ctx context.Context, q Querier) error
+	// Restore runs on the connection once the transaction was committed or rolled back, or the migration ran
+	Restore(ctx context.Context, conn *sql.Conn) error
+}
+
+// Querier is a transaction or a connection, which ConnHook.Check runs in
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Timeouts can optionally be implemented by a Dialect for a database able to limit how long statements run and wait
+// for locks, as set with WithStatementTimeout and WithLockTimeout
+type Timeouts interface {
+	// SetTimeouts returns the statements setting the limits, leaving a limit of 0 unset. The limits apply to the current
+	// transaction, or to the connection a migration run without one is pinned to if session is true.
+	SetTimeouts(statement, lock time.Duration, session bool) []string
+	// ResetTimeouts returns the statements resetting the limits SetTimeouts set for a connection, before it is returned
+	// to the pool
+	ResetTimeouts(statement, lock time.Duration) []string
+	// TimedOut returns whether err is a statement exceeding the statement or the lock timeout
+	TimedOut(err error) (statement, lock bool)
+}
@@ -0,0 +1,69 @@
+package sqldb
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+func WithLog(f LogFunc) Option {
+	return func(a *Adapter) {
+		a.log = f
+	}
+}
+
+// WithLogger logs every migration taken down or applied, and a summary of each at info level, and every statement
+// executed by a migration at debug level, as structured records with the migration name, direction, statement index,
+// duration and rows affected
+func WithLogger(l *slog.Logger) Option {
+	return func(a *Adapter) {
+		if l != nil {
+			a.logger = l
+		}
+	}
+}
+
+func WithTxOptions(txOptions *sql.TxOptions) Option {
+	return func(a *Adapter) {
+		a.txOptions = txOptions
+	}
+}
+
+// WithTable sets the name of the table applied migrations are recorded in. Defaults to "migrations".
+func WithTable(name string) Option {
+	return func(a *Adapter) {
+		if name != "" {
+			a.table = name
+		}
+	}
+}
+
+// WithSchema puts the migrations table in the named schema, which must exist, instead of the default one
+func WithSchema(name string) Option {
+	return func(a *Adapter) {
+		a.schema = name
+	}
+}
+
+// WithProgress calls f after each statement of a migration was executed, reporting how far the migration has come
+func WithProgress(f ProgressFunc) Option {
+	return func(a *Adapter) {
+		a.progress = f
+	}
+}
+
+// WithLockTimeout limits how long the statements of migrations wait for a lock before failing with a
+// *migrate.TimeoutError, whether they run in a transaction or not. Only applies to dialects implementing Timeouts.
+func WithLockTimeout(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.lockTimeout = d
+	}
+}
+
+// WithStatementTimeout cancels statements that run for longer with a *migrate.TimeoutError, and sets the limit in the
+// database for dialects implementing Timeouts
+func WithStatementTimeout(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.statementTimeout = d
+	}
+}
@@ -0,0 +1,68 @@
+// Package sqldbtest sets up a sqldb.Adapter on a sqlmock database for testing dialects
+package sqldbtest
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/mertenvg/migrate/adapter/sqldb"
+	"github.com/mertenvg/migrate/pkg/checksum"
+)
+
+// Queries are the statements an Adapter keeps track of migrations with, as the dialect under test is expected to
+// write them
+type Queries struct {
+	Store            []string
+	Add              string
+	Migrations       string
+	Applied          string
+	Checksums        string
+	UpdateChecksum   string
+	RollbackWithName string
+	RemoveWithName   string
+}
+
+var matchWhitespace = regexp.MustCompile("\\s+")
+
+// MockFriendly returns a sqlmock expectation matching q regardless of its whitespace
+func MockFriendly(q string) string {
+	return regexp.QuoteMeta(strings.TrimSpace(matchWhitespace.ReplaceAllString(q, " ")))
+}
+
+// Sum returns the checksum recorded for a migration reading s
+func Sum(s string) string {
+	sum, _ := checksum.Sum(bytes.NewBufferString(s))
+	return sum
+}
+
+// NewAdapter sets up an Adapter speaking d on a sqlmock database, expecting Setup to run q, and closes the database
+// when the test ends
+func NewAdapter(t testing.TB, d sqldb.Dialect, q Queries, options ...sqldb.Option) (*sqldb.Adapter, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() { sqldb.MustClose(db, nil) })
+
+	for _, ddl := range q.Store {
+		mock.ExpectExec(MockFriendly(ddl)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectPrepare(MockFriendly(q.Add))
+	mock.ExpectPrepare(MockFriendly(q.Migrations))
+	mock.ExpectPrepare(MockFriendly(q.Applied))
+	mock.ExpectPrepare(MockFriendly(q.Checksums))
+	mock.ExpectPrepare(MockFriendly(q.UpdateChecksum))
+	mock.ExpectPrepare(MockFriendly(q.RollbackWithName))
+	mock.ExpectPrepare(MockFriendly(q.RemoveWithName))
+
+	a := sqldb.NewAdapter(db, d, options...)
+	if err := a.Setup(); err != nil {
+		t.Fatalf("Setup() unexpected error %v", err)
+	}
+	return a, mock
+}
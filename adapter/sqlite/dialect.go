@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mertenvg/migrate/adapter/sqldb"
+	"github.com/mertenvg/migrate/pkg/reader"
+)
+
+// foreign_keys cannot be changed inside a transaction, so it is switched off on the connection before the transaction
+// or the migration run without one begins, and restored after it ends. foreign_key_check reports the violations left by the migrations.
+const (
+	foreignKeys     = `PRAGMA foreign_keys`
+	foreignKeysOff  = `PRAGMA foreign_keys = OFF`
+	foreignKeysOn   = `PRAGMA foreign_keys = ON`
+	foreignKeyCheck = `PRAGMA foreign_key_check`
+)
+
+// storeExists returns whether the table exists, formatted with the sqlite_master table to look it up in
+const storeExists = `SELECT EXISTS (SELECT 1 FROM %s WHERE type = 'table' AND name = ?)`
+
+// maxReportedViolations limits how many foreign key violations are listed in the error returned by Commit
+const maxReportedViolations = 10
+
+// createTrigger matches a CREATE TRIGGER statement up to the BEGIN of its body, which SQLReader splits at every
+// semicolon in the body
+var createTrigger = regexp.MustCompile(`(?is)^CREATE\s+(TEMP\s+|TEMPORARY\s+)?TRIGGER\b.*\bBEGIN\b`)
+
+// Dialect is the SQLite sqldb.Dialect. Unless KeepForeignKeys is set, migrations run with foreign key enforcement
+// switched off, as SQLite recommends for schema changes, and are only committed if foreign_key_check finds no
+// violations. PRAGMA foreign_keys statements in migrations have no effect as they run in a transaction.
+type Dialect struct {
+	// KeepForeignKeys leaves foreign key enforcement as it is while migrations run
+	KeepForeignKeys bool
+}
+
+var (
+	_ sqldb.Dialect  = Dialect{}
+	_ sqldb.Splitter = Dialect{}
+	_ sqldb.ConnHook = Dialect{}
+)
+
+// NewAdapter creates a sqldb.Adapter recording applied migrations in the "migrations" table of the SQLite database,
+// switching foreign key enforcement off while they run
+func NewAdapter(db *sql.DB, options ...sqldb.Option) *sqldb.Adapter {
+	return sqldb.NewAdapter(db, Dialect{}, options...)
+}
+
+// Placeholder returns ?
+func (Dialect) Placeholder(n int) string {
+	return "?"
+}
+
+// Quote returns the identifier in double quotes
+func (Dialect) Quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// MigrationStore returns the migrations table with SQLite's column types
+func (Dialect) MigrationStore(table string) []string {
+	return []string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		"name" TEXT NOT NULL PRIMARY KEY,
+		"created_at" TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		"rollback" TEXT NULL,
+		"checksum" TEXT NULL
+	)`, table)}
+}
+
+// StoreExists looks the table up in sqlite_master of the main database, or of the attached database given as schema
+func (d Dialect) StoreExists(ctx context.Context, db *sql.DB, schema, table string) (bool, error) {
+	master := "sqlite_master"
+	if schema != "" {
+		master = d.Quote(schema) + "." + master
+	}
+	var exists bool
+	err := db.QueryRowContext(ctx, fmt.Sprintf(storeExists, master), table).Scan(&exists)
+	return exists, err
+}
+
+// Locker returns nil, as SQLite only lets one writer in at a time anyway
+func (Dialect) Locker() sqldb.Locker {
+	return nil
+}
+
+// NoTransaction returns false, as SQLite runs DDL in transactions
+func (Dialect) NoTransaction(q string) bool {
+	return false
+}
+
+// Next returns the next statement from r, joining the body of a CREATE TRIGGER that SQLReader split at its semicolons
+// back together
+func (Dialect) Next(r *reader.SQLReader) (string, error) {
+	q, err := r.Next()
+	if err != nil || !createTrigger.MatchString(q) {
+		return q, err
+	}
+	parts := []string{q}
+	for !strings.EqualFold(parts[len(parts)-1], "END") {
+		part, err := r.Next()
+		if err != nil {
+			return "", err
+		}
+		if part == "" {
+			return "", fmt.Errorf("trigger body is missing its END: '%s'", q)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ";\n"), nil
+}
+
+// Prepare switches foreign key enforcement off on the connection, returning true if it was on
+func (d Dialect) Prepare(ctx context.Context, conn *sql.Conn) (bool, error) {
+	if d.KeepForeignKeys {
+		return false, nil
+	}
+	var enabled bool
+	if err := conn.QueryRowContext(ctx, foreignKeys).Scan(&enabled); err != nil {
+		return false, fmt.Errorf("failed to read foreign_keys: %w", err)
+	}
+	if !enabled {
+		return false, nil
+	}
+	if _, err := conn.ExecContext(ctx, foreignKeysOff); err != nil {
+		return false, fmt.Errorf("failed to switch off foreign_keys: %w", err)
+	}
+	return true, nil
+}
+
+// Check returns an error listing the foreign key violations left by the migrations
+func (Dialect) Check(ctx context.Context, q sqldb.Querier) error {
+	rows, err := q.QueryContext(ctx, foreignKeyCheck)
+	if err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	defer sqldb.MustClose(rows, nil)
+
+	var violations []string
+	count := 0
+	for rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return fmt.Errorf("failed to check foreign keys: %w", err)
+		}
+		count++
+		if count <= maxReportedViolations {
+			violations = append(violations, fmt.Sprintf("%s row %d references %s", table, rowid.Int64, parent))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+	if count > maxReportedViolations {
+		violations = append(violations, fmt.Sprintf("and %d more", count-maxReportedViolations))
+	}
+	return fmt.Errorf("%d foreign key violations: %s", count, strings.Join(violations, ", "))
+}
+
+// Restore switches foreign key enforcement back on
+func (Dialect) Restore(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, foreignKeysOn); err != nil {
+		return fmt.Errorf("failed to switch foreign_keys back on: %w", err)
+	}
+	return nil
+}
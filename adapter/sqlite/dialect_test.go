@@ -0,0 +1,221 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/mertenvg/migrate/adapter/sqldb"
+	"github.com/mertenvg/migrate/adapter/sqldb/sqldbtest"
+)
+
+// queries are the statements sqldb.Adapter keeps track of migrations with, as the SQLite Dialect quotes them
+var queries = sqldbtest.Queries{
+	Store: []string{`CREATE TABLE IF NOT EXISTS "migrations" (
+		"name" TEXT NOT NULL PRIMARY KEY,
+		"created_at" TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		"rollback" TEXT NULL,
+		"checksum" TEXT NULL
+	)`},
+	Add:              `INSERT INTO "migrations" ("name", "rollback", "checksum") VALUES (?, ?, ?)`,
+	Migrations:       `SELECT "name" FROM "migrations" ORDER BY "name"`,
+	Applied:          `SELECT "name" FROM "migrations" ORDER BY "created_at", "name"`,
+	Checksums:        `SELECT "name", "checksum" FROM "migrations" ORDER BY "name"`,
+	UpdateChecksum:   `UPDATE "migrations" SET "checksum" = ? WHERE "name" = ?`,
+	RollbackWithName: `SELECT "rollback" FROM "migrations" WHERE "name" = ?`,
+	RemoveWithName:   `DELETE FROM "migrations" WHERE "name" = ?`,
+}
+
+// expectBegin expects Begin to switch off foreign keys if enabled is true
+func expectBegin(mock sqlmock.Sqlmock, enabled bool) {
+	mock.ExpectQuery(sqldbtest.MockFriendly(foreignKeys)).WillReturnRows(sqlmock.NewRows([]string{"foreign_keys"}).AddRow(enabled))
+	if enabled {
+		mock.ExpectExec(sqldbtest.MockFriendly(foreignKeysOff)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectBegin()
+}
+
+func newAdapter(t *testing.T, d Dialect) (*sqldb.Adapter, sqlmock.Sqlmock) {
+	return sqldbtest.NewAdapter(t, d, queries)
+}
+
+func TestAdapter_Up(t *testing.T) {
+	up := `BEGIN TRANSACTION;
+CREATE TABLE a (id INTEGER PRIMARY KEY, updated TEXT);
+CREATE TRIGGER a_updated AFTER UPDATE ON a BEGIN
+	UPDATE a SET updated = CURRENT_TIMESTAMP WHERE id = NEW.id;
+	SELECT 1;
+END;
+COMMIT;`
+
+	a, mock := newAdapter(t, Dialect{})
+	expectBegin(mock, true)
+	mock.ExpectExec(sqldbtest.MockFriendly("CREATE TABLE a (id INTEGER PRIMARY KEY, updated TEXT)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(sqldbtest.MockFriendly("CREATE TRIGGER a_updated AFTER UPDATE ON a BEGIN UPDATE a SET updated = CURRENT_TIMESTAMP WHERE id = NEW.id; SELECT 1; END")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(sqldbtest.MockFriendly(queries.Add)).WithArgs("aaa", "DROP TABLE a;", sqldbtest.Sum(up)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(sqldbtest.MockFriendly(foreignKeyCheck)).WillReturnRows(sqlmock.NewRows([]string{"table", "rowid", "parent", "fkid"}))
+	mock.ExpectCommit()
+	mock.ExpectExec(sqldbtest.MockFriendly(foreignKeysOn)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := a.Begin(context.Background()); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString(up), bytes.NewBufferString("DROP TABLE a;")); err != nil {
+		t.Errorf("Up() unexpected error %v", err)
+	}
+	if err := a.Commit(); err != nil {
+		t.Errorf("Commit() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithUnterminatedTrigger(t *testing.T) {
+	a, mock := newAdapter(t, Dialect{})
+	expectBegin(mock, false)
+
+	if err := a.Begin(context.Background()); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	err := a.Up("aaa", bytes.NewBufferString("CREATE TRIGGER t AFTER INSERT ON a BEGIN SELECT 1;"), nil)
+	if err == nil || !strings.Contains(err.Error(), "missing its END") {
+		t.Errorf("Up() error = %v, want missing END", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Begin_WithKeepForeignKeys(t *testing.T) {
+	a, mock := newAdapter(t, Dialect{KeepForeignKeys: true})
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	if err := a.Begin(context.Background()); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	if err := a.Commit(); err != nil {
+		t.Errorf("Commit() unexpected error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Commit_WithForeignKeyViolations(t *testing.T) {
+	a, mock := newAdapter(t, Dialect{})
+	expectBegin(mock, true)
+	mock.ExpectExec(sqldbtest.MockFriendly("DELETE FROM parent")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(sqldbtest.MockFriendly(queries.Add)).WithArgs("aaa", "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(sqldbtest.MockFriendly(foreignKeyCheck)).WillReturnRows(sqlmock.NewRows([]string{"table", "rowid", "parent", "fkid"}).AddRow("child", 7, "parent", 0))
+	mock.ExpectRollback()
+	mock.ExpectExec(sqldbtest.MockFriendly(foreignKeysOn)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := a.Begin(context.Background()); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	if err := a.Up("aaa", bytes.NewBufferString("DELETE FROM parent;"), nil); err != nil {
+		t.Fatalf("Up() unexpected error %v", err)
+	}
+	err := a.Commit()
+	if err == nil || !strings.Contains(err.Error(), "child row 7 references parent") {
+		t.Errorf("Commit() error = %v, want the foreign key violation", err)
+	}
+	if err := a.Rollback(); err == nil {
+		t.Errorf("Commit() kept the transaction after failing")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Rollback(t *testing.T) {
+	a, mock := newAdapter(t, Dialect{})
+	expectBegin(mock, true)
+	mock.ExpectRollback()
+	mock.ExpectExec(sqldbtest.MockFriendly(foreignKeysOn)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := a.Begin(nil); err != nil {
+		t.Fatalf("Begin() unexpected error %v", err)
+	}
+	if err := a.Rollback(); err != nil {
+		t.Errorf("Rollback() unexpected error %v", err)
+	}
+	if err := a.Rollback(); err == nil {
+		t.Errorf("Rollback() without a transaction error = %v, wantErr %v", err, true)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Begin_Fail(t *testing.T) {
+	a, mock := newAdapter(t, Dialect{})
+	mock.ExpectQuery(sqldbtest.MockFriendly(foreignKeys)).WillReturnRows(sqlmock.NewRows([]string{"foreign_keys"}).AddRow(true))
+	mock.ExpectExec(sqldbtest.MockFriendly(foreignKeysOff)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin().WillReturnError(errors.New("database is locked"))
+	mock.ExpectExec(sqldbtest.MockFriendly(foreignKeysOn)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := a.Begin(context.Background()); err == nil {
+		t.Errorf("Begin() error = %v, wantErr %v", err, true)
+	}
+	if err := a.Rollback(); err == nil {
+		t.Errorf("Begin() kept the transaction after failing")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestAdapter_Up_WithoutTransaction(t *testing.T) {
+	a, mock := newAdapter(t, Dialect{})
+	mock.ExpectQuery(sqldbtest.MockFriendly(foreignKeys)).WillReturnRows(sqlmock.NewRows([]string{"foreign_keys"}).AddRow(true))
+	mock.ExpectExec(sqldbtest.MockFriendly(foreignKeysOff)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(sqldbtest.MockFriendly("DELETE FROM parent")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(sqldbtest.MockFriendly(foreignKeyCheck)).WillReturnRows(sqlmock.NewRows([]string{"table", "rowid", "parent", "fkid"}).AddRow("child", 7, "parent", 0))
+	mock.ExpectExec(sqldbtest.MockFriendly(foreignKeysOn)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// the violation fails the migration before it is recorded
+	err := a.Up("aaa", bytes.NewBufferString("DELETE FROM parent;"), nil)
+	if err == nil || !strings.Contains(err.Error(), "child row 7 references parent") {
+		t.Errorf("Up() error = %v, want the foreign key violation", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
+
+func TestDialect_StoreExists(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		query  string
+	}{
+		{name: "main", query: `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)`},
+		{name: "attached", schema: "aux", query: `SELECT EXISTS (SELECT 1 FROM "aux".sqlite_master WHERE type = 'table' AND name = ?)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer sqldb.MustClose(db, nil)
+
+			mock.ExpectQuery(sqldbtest.MockFriendly(tt.query)).WithArgs("migrations").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+			got, err := Dialect{}.StoreExists(context.Background(), db, tt.schema, "migrations")
+			if err != nil || !got {
+				t.Errorf("StoreExists() = %v, %v, want %v", got, err, true)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/mertenvg/migrate/pkg/checksum"
+)
+
+// ChecksumPolicy decides what happens when the up script of an applied migration changed since it was applied
+type ChecksumPolicy int
+
+const (
+	// ChecksumError fails before anything is changed, returning a *ChecksumMismatchError
+	ChecksumError ChecksumPolicy = iota
+	// ChecksumWarn logs the changed migrations and carries on
+	ChecksumWarn
+	// ChecksumIgnore skips checksum verification
+	ChecksumIgnore
+)
+
+// sum returns the checksum of the migration's up script
+func sum(migration Migration) (string, error) {
+	defer migration.Close()
+	up, err := openUp(migration)
+	if err != nil {
+		return "", fmt.Errorf("failed to open migration '%v': %w", migration.Name(), err)
+	}
+	s, err := checksum.Sum(up)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration '%v': %w", migration.Name(), err)
+	}
+	return s, nil
+}
+
+// verifyChecksums compares the recorded checksums of applied migrations with the provider's migrations. Migrations
+// without a recorded checksum are not verified.
+func (m *Migrate) verifyChecksums(ctx context.Context, s *state) error {
+	c, ok := m.checksummer()
+	if !ok || m.checksum == ChecksumIgnore || len(s.applied) == 0 {
+		return nil
+	}
+	recorded, err := c.ChecksumsContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get checksums: %w", err)
+	}
+
+	var changed []string
+	for _, name := range s.names {
+		want := recorded[name]
+		if want == "" || !slices.Contains(s.applied, name) {
+			continue
+		}
+		got, err := sum(s.migrations[name])
+		if err != nil {
+			return err
+		}
+		if got != want {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if m.checksum == ChecksumWarn {
+		m.logAttrs(ctx, slog.LevelWarn, "applied migrations changed since they were applied", slog.Any("migrations", changed))
+		return nil
+	}
+	return &ChecksumMismatchError{Names: changed}
+}
+
+// Rebaseline records the current checksum of the named applied migrations, accepting any changes made to them since
+// they were applied. Every applied migration known to the provider is re-baselined if no names are given.
+func (m *Migrate) Rebaseline(ctx context.Context, names ...string) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	c, ok := m.checksummer()
+	if !ok {
+		return fmt.Errorf("adapter does not record checksums")
+	}
+
+	if len(names) == 0 {
+		for _, name := range s.names {
+			if slices.Contains(s.applied, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		migration, ok := s.migrations[name]
+		if !ok {
+			return fmt.Errorf("migration '%v' not found in provider", name)
+		}
+		if !slices.Contains(s.applied, name) {
+			return fmt.Errorf("migration '%v' has not been applied", name)
+		}
+		got, err := sum(migration)
+		if err != nil {
+			return err
+		}
+		if err := c.SetChecksumContext(ctx, name, got); err != nil {
+			return fmt.Errorf("failed to set checksum for migration '%v': %w", name, err)
+		}
+	}
+	return nil
+}
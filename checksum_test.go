@@ -0,0 +1,152 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mertenvg/migrate/pkg/checksum"
+)
+
+type MockChecksumAdapter struct {
+	MockAdapter
+	checksumsErr   error
+	setChecksumErr error
+	checksums      map[string]string
+}
+
+func (m *MockChecksumAdapter) Checksums() (map[string]string, error) {
+	return m.checksums, m.checksumsErr
+}
+
+func (m *MockChecksumAdapter) SetChecksum(name, sum string) error {
+	if m.setChecksumErr != nil {
+		return m.setChecksumErr
+	}
+	m.checksums[name] = sum
+	return nil
+}
+
+func mockSum(name string) string {
+	sum, _ := checksum.Sum(bytes.NewBufferString("up " + name))
+	return sum
+}
+
+func TestMigrate_Migrate_ChecksumPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    ChecksumPolicy
+		checksums map[string]string
+		wantErr   bool
+	}{
+		{
+			name:      "unchanged with error policy",
+			policy:    ChecksumError,
+			checksums: map[string]string{"aaa": mockSum("aaa")},
+		},
+		{
+			name:      "not recorded with error policy",
+			policy:    ChecksumError,
+			checksums: map[string]string{"aaa": ""},
+		},
+		{
+			name:      "changed with error policy",
+			policy:    ChecksumError,
+			checksums: map[string]string{"aaa": "changed"},
+			wantErr:   true,
+		},
+		{
+			name:      "changed with warn policy",
+			policy:    ChecksumWarn,
+			checksums: map[string]string{"aaa": "changed"},
+		},
+		{
+			name:      "changed with ignore policy",
+			policy:    ChecksumIgnore,
+			checksums: map[string]string{"aaa": "changed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockChecksumAdapter{MockAdapter: MockAdapter{applied: []string{"aaa"}}, checksums: tt.checksums}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb"}}), WithChecksumPolicy(tt.policy))
+			err := m.Migrate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var mismatchErr *ChecksumMismatchError
+			if tt.wantErr && (!errors.As(err, &mismatchErr) || !reflect.DeepEqual(mismatchErr.Names, []string{"aaa"})) {
+				t.Errorf("Migrate() error = %v, want ChecksumMismatchError for [aaa]", err)
+			}
+		})
+	}
+}
+
+func TestMigrate_Migrate_WithChecksumsError(t *testing.T) {
+	a := &MockChecksumAdapter{MockAdapter: MockAdapter{applied: []string{"aaa"}}, checksumsErr: fmt.Errorf("fail checksums")}
+	m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa"}}))
+	if err := m.Migrate(context.Background()); err == nil {
+		t.Errorf("Migrate() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestMigrate_Rebaseline(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       Adapter
+		names   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "rebaseline all",
+			a:    &MockChecksumAdapter{MockAdapter: MockAdapter{applied: []string{"aaa", "bbb"}}, checksums: map[string]string{"aaa": "changed", "bbb": ""}},
+			want: map[string]string{"aaa": mockSum("aaa"), "bbb": mockSum("bbb")},
+		},
+		{
+			name:  "rebaseline named",
+			a:     &MockChecksumAdapter{MockAdapter: MockAdapter{applied: []string{"aaa", "bbb"}}, checksums: map[string]string{"aaa": "changed", "bbb": "changed"}},
+			names: []string{"bbb"},
+			want:  map[string]string{"aaa": "changed", "bbb": mockSum("bbb")},
+		},
+		{
+			name:    "rebaseline pending",
+			a:       &MockChecksumAdapter{MockAdapter: MockAdapter{applied: []string{"aaa"}}, checksums: map[string]string{"aaa": "changed"}},
+			names:   []string{"ccc"},
+			want:    map[string]string{"aaa": "changed"},
+			wantErr: true,
+		},
+		{
+			name:    "rebaseline unknown",
+			a:       &MockChecksumAdapter{MockAdapter: MockAdapter{applied: []string{"aaa"}}, checksums: map[string]string{"aaa": "changed"}},
+			names:   []string{"zzz"},
+			want:    map[string]string{"aaa": "changed"},
+			wantErr: true,
+		},
+		{
+			name:    "rebaseline with set checksum error",
+			a:       &MockChecksumAdapter{MockAdapter: MockAdapter{applied: []string{"aaa"}}, checksums: map[string]string{"aaa": "changed"}, setChecksumErr: fmt.Errorf("fail set checksum")},
+			want:    map[string]string{"aaa": "changed"},
+			wantErr: true,
+		},
+		{
+			name:    "rebaseline without checksum support",
+			a:       &MockAdapter{applied: []string{"aaa"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(WithAdapter(tt.a), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc"}}))
+			if err := m.Rebaseline(context.Background(), tt.names...); (err != nil) != tt.wantErr {
+				t.Errorf("Rebaseline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if c, ok := tt.a.(*MockChecksumAdapter); ok && !reflect.DeepEqual(c.checksums, tt.want) {
+				t.Errorf("Rebaseline() checksums = %v, want %v", c.checksums, tt.want)
+			}
+		})
+	}
+}
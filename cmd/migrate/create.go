@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mertenvg/migrate/provider/files"
+)
+
+func create(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error {
+	fs := subcommand("create", "[-numbering timestamp|sequence] <name>", stderr)
+	numbering := fs.String("numbering", env("MIGRATE_NUMBERING", "timestamp"), "number the migration with a timestamp or the next number in sequence, defaults to $MIGRATE_NUMBERING")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+
+	var n files.Numbering
+	switch *numbering {
+	case "timestamp":
+		n = files.NumberTimestamp
+	case "sequence":
+		n = files.NumberSequence
+	default:
+		fs.Usage()
+		return errUsage
+	}
+
+	up, down, err := files.Create(cfg.dir, strings.Join(fs.Args(), " "), files.WithNumbering(n))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, up)
+	fmt.Fprintln(stdout, down)
+	return nil
+}
@@ -0,0 +1,348 @@
+// Command migrate applies, takes down and inspects the migration files in a directory on a postgres database.
+//
+// Usage:
+//
+//	migrate [flags] <command> [arguments]
+//
+// The database and migrations directory are set with the -dsn and -dir flags, or the MIGRATE_DSN and MIGRATE_DIR
+// environment variables. DATABASE_URL is used when neither -dsn nor MIGRATE_DSN is set.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	_ "github.com/lib/pq"
+
+	"github.com/mertenvg/migrate"
+	"github.com/mertenvg/migrate/adapter/postgres"
+	"github.com/mertenvg/migrate/adapter/sqldb"
+	"github.com/mertenvg/migrate/provider/files"
+)
+
+const usage = `Usage: migrate [flags] <command> [arguments]
+
+Commands:
+  up [-to name]           apply pending migrations, up to and including the named one if given
+  down [-n steps | -to name]
+                          take down the last applied migration, the last steps migrations, or every migration
+                          applied after the named one
+  status                  list every migration and whether it is applied, pending, out of order or missing
+  plan                    print the SQL up would execute without changing anything
+  create [-numbering timestamp|sequence] <name>
+                          create an empty up and down migration file pair
+  redo                    take down the last applied migration and apply it again
+  baseline [name ...]     record the current checksum of the named applied migrations, or of every applied one
+  validate [-require-down]
+                          check the migration files for problems, and against the applied migrations if a
+                          database is set, without changing anything
+
+Flags:
+`
+
+// errUsage is returned when the command line is invalid, after the usage was printed
+var errUsage = errors.New("invalid usage")
+
+// config holds the global flags
+type config struct {
+	dsn     string
+	dir     string
+	verbose bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+		}
+		stop()
+		os.Exit(1)
+	}
+}
+
+// run parses the command line and runs the command
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	var cfg config
+	fs.StringVar(&cfg.dsn, "dsn", env("MIGRATE_DSN", os.Getenv("DATABASE_URL")), "database connection string, defaults to $MIGRATE_DSN or $DATABASE_URL")
+	fs.StringVar(&cfg.dir, "dir", env("MIGRATE_DIR", "migrations"), "migrations directory, defaults to $MIGRATE_DIR")
+	fs.BoolVar(&cfg.verbose, "v", false, "log every migration and statement")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+	return cmd(ctx, &cfg, fs.Args()[1:], stdout, stderr)
+}
+
+// env returns the value of the environment variable, or def if it is not set
+func env(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// command runs a subcommand with the arguments following its name
+type command func(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error
+
+var commands = map[string]command{
+	"up":       up,
+	"down":     down,
+	"status":   status,
+	"plan":     plan,
+	"create":   create,
+	"redo":     redo,
+	"baseline": baseline,
+	"validate": validate,
+}
+
+// open connects to the database and loads the migrations directory, returning a Migrate for them and a func to close
+// the database connection
+func open(ctx context.Context, cfg *config, stderr io.Writer) (*migrate.Migrate, func(), error) {
+	if cfg.dsn == "" {
+		return nil, nil, fmt.Errorf("no database set, use -dsn, MIGRATE_DSN or DATABASE_URL")
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	if cfg.verbose {
+		logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	p, err := files.Load(cfg.dir, files.WithLogger(logger))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := sql.Open("postgres", cfg.dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		postgres.MustClose(db, nil)
+		return nil, nil, fmt.Errorf("cannot connect to database: %w", err)
+	}
+
+	m := migrate.New(
+		migrate.WithProvider(p),
+		migrate.WithAdapter(postgres.NewAdapter(db, sqldb.WithLogger(logger))),
+		migrate.WithLogger(logger),
+	)
+	return m, func() { postgres.MustClose(db, nil) }, nil
+}
+
+// subcommand returns a flag set for the named subcommand that prints its usage line on errors
+func subcommand(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: migrate %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the subcommand's arguments, requiring between min and max positional arguments. There is no upper
+// limit if max is negative.
+func parse(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() < min || (max >= 0 && fs.NArg() > max) {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+func up(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error {
+	fs := subcommand("up", "[-to name]", stderr)
+	to := fs.String("to", "", "apply pending migrations up to and including this one")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	m, closeDB, err := open(ctx, cfg, stderr)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if *to != "" {
+		return m.UpTo(ctx, *to)
+	}
+	return m.Migrate(ctx)
+}
+
+func down(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error {
+	fs := subcommand("down", "[-n steps | -to name]", stderr)
+	steps := fs.Int("n", 1, "number of applied migrations to take down")
+	to := fs.String("to", "", "take down every migration applied after this one")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	m, closeDB, err := open(ctx, cfg, stderr)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if *to != "" {
+		return m.DownTo(ctx, *to)
+	}
+	return m.Rollback(ctx, *steps)
+}
+
+func status(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error {
+	if err := parse(subcommand("status", "", stderr), args, 0, 0); err != nil {
+		return err
+	}
+
+	m, closeDB, err := open(ctx, cfg, stderr)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, s := range statuses {
+		fmt.Fprintf(tw, "%s\t%s\n", s.State, s.Name)
+	}
+	return tw.Flush()
+}
+
+func plan(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error {
+	if err := parse(subcommand("plan", "", stderr), args, 0, 0); err != nil {
+		return err
+	}
+
+	m, closeDB, err := open(ctx, cfg, stderr)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	p, err := m.Plan(ctx)
+	if err != nil {
+		return err
+	}
+	printPlan(stdout, p)
+	return nil
+}
+
+// printPlan writes the SQL of every step in the plan, each preceded by a comment naming it
+func printPlan(w io.Writer, p *migrate.Plan) {
+	if p.Empty() {
+		fmt.Fprintln(w, "-- nothing to do")
+		return
+	}
+	for _, step := range p.Down {
+		fmt.Fprintf(w, "-- down %s\n%s\n\n", step.Name, strings.TrimSpace(step.SQL))
+	}
+	for _, step := range p.Up {
+		fmt.Fprintf(w, "-- up %s\n%s\n\n", step.Name, strings.TrimSpace(step.SQL))
+	}
+}
+
+func redo(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error {
+	if err := parse(subcommand("redo", "", stderr), args, 0, 0); err != nil {
+		return err
+	}
+
+	m, closeDB, err := open(ctx, cfg, stderr)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return m.Redo(ctx)
+}
+
+func baseline(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error {
+	fs := subcommand("baseline", "[name ...]", stderr)
+	if err := parse(fs, args, 0, -1); err != nil {
+		return err
+	}
+
+	m, closeDB, err := open(ctx, cfg, stderr)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return m.Rebaseline(ctx, fs.Args()...)
+}
+
+func validate(ctx context.Context, cfg *config, args []string, stdout, stderr io.Writer) error {
+	fs := subcommand("validate", "[-require-down]", stderr)
+	requireDown := fs.Bool("require-down", false, "report migrations without a down file")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	var opts []files.ValidateOption
+	if *requireDown {
+		opts = append(opts, files.WithRequireDown())
+	}
+	findings, err := files.Validate(os.DirFS(cfg.dir), opts...)
+	if err != nil {
+		return fmt.Errorf("cannot validate migrations in '%v': %w", cfg.dir, err)
+	}
+	for _, f := range findings {
+		fmt.Fprintf(stdout, "%s\n", filepath.Join(cfg.dir, f.String()))
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("found %d problems with the migrations in '%v'", len(findings), cfg.dir)
+	}
+
+	// the files are fine, check them against the applied migrations if there is a database
+	if cfg.dsn != "" {
+		m, closeDB, err := open(ctx, cfg, stderr)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+
+		if err := m.Verify(ctx); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(stdout, "ok")
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mertenvg/migrate"
+)
+
+func TestRun_Usage(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    error
+		wantStderr string
+	}{
+		{
+			name:       "no command",
+			args:       nil,
+			wantErr:    errUsage,
+			wantStderr: "Usage: migrate",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"sideways"},
+			wantErr:    errUsage,
+			wantStderr: `unknown command "sideways"`,
+		},
+		{
+			name:       "unknown flag",
+			args:       []string{"-nope", "up"},
+			wantErr:    errUsage,
+			wantStderr: "flag provided but not defined: -nope",
+		},
+		{
+			name:       "unexpected argument",
+			args:       []string{"up", "aaa"},
+			wantErr:    errUsage,
+			wantStderr: "Usage: migrate up [-to name]",
+		},
+		{
+			name:       "create without name",
+			args:       []string{"create"},
+			wantErr:    errUsage,
+			wantStderr: "Usage: migrate create [-numbering timestamp|sequence] <name>",
+		},
+		{
+			name:       "help",
+			args:       []string{"-h"},
+			wantStderr: "Commands:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(context.Background(), tt.args, &stdout, &stderr)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("run() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("run() stderr = %q, want it to contain %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRun_WithoutDSN(t *testing.T) {
+	t.Setenv("MIGRATE_DSN", "")
+	t.Setenv("DATABASE_URL", "")
+
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), []string{"-dir", t.TempDir(), "status"}, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "no database set") {
+		t.Errorf("run() error = %v, want no database set", err)
+	}
+}
+
+func TestRun_Create(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+	t.Setenv("MIGRATE_DIR", dir)
+	t.Setenv("MIGRATE_NUMBERING", "sequence")
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"create", "Add users", "table!"}, &stdout, &stderr); err != nil {
+		t.Fatalf("run() unexpected error %v", err)
+	}
+	if err := run(context.Background(), []string{"create", "add orders"}, &stdout, &stderr); err != nil {
+		t.Fatalf("run() unexpected error %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("cannot read migrations directory: %v", err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	want := []string{
+		"0001_add_users_table.down.sql",
+		"0001_add_users_table.up.sql",
+		"0002_add_orders.down.sql",
+		"0002_add_orders.up.sql",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("create made %v, want %v", got, want)
+	}
+	if !strings.Contains(stdout.String(), filepath.Join(dir, want[1])) {
+		t.Errorf("run() stdout = %q, want it to list %q", stdout.String(), want[1])
+	}
+}
+
+func TestRun_CreateWithUnknownNumbering(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), []string{"-dir", t.TempDir(), "create", "-numbering", "roman", "add users"}, &stdout, &stderr)
+	if !errors.Is(err, errUsage) {
+		t.Errorf("run() error = %v, want %v", err, errUsage)
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		plan *migrate.Plan
+		want string
+	}{
+		{
+			name: "empty plan",
+			plan: &migrate.Plan{},
+			want: "-- nothing to do\n",
+		},
+		{
+			name: "down and up",
+			plan: &migrate.Plan{
+				Down: []migrate.PlanStep{{Name: "ccc", SQL: "DROP TABLE ccc;\n"}},
+				Up:   []migrate.PlanStep{{Name: "bbb", SQL: "CREATE TABLE bbb ();\n"}},
+			},
+			want: "-- down ccc\nDROP TABLE ccc;\n\n-- up bbb\nCREATE TABLE bbb ();\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printPlan(&buf, tt.plan)
+			if buf.String() != tt.want {
+				t.Errorf("printPlan() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_Validate(t *testing.T) {
+	t.Setenv("MIGRATE_DSN", "")
+	t.Setenv("DATABASE_URL", "")
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0001_init.up.sql"), []byte("CREATE TABLE a ();\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"-dir", dir, "validate"}, &stdout, &stderr); err != nil {
+		t.Errorf("run() unexpected error %v", err)
+	}
+	if stdout.String() != "ok\n" {
+		t.Errorf("run() stdout = %q, want %q", stdout.String(), "ok\n")
+	}
+
+	stdout.Reset()
+	err := run(context.Background(), []string{"-dir", dir, "validate", "-require-down"}, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "found 1 problems") {
+		t.Errorf("run() error = %v, want 1 problem", err)
+	}
+	if want := filepath.Join(dir, "0001_init.up.sql") + ": no down file\n"; stdout.String() != want {
+		t.Errorf("run() stdout = %q, want %q", stdout.String(), want)
+	}
+}
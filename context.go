@@ -0,0 +1,138 @@
+package migrate
+
+import (
+	"context"
+	"io"
+)
+
+// AdaptContext returns a as a ContextAdapter. Adapters that only implement Adapter are wrapped so the context is
+// checked before each call, but it cannot cancel a call that is already running.
+func AdaptContext(a Adapter) ContextAdapter {
+	if a == nil {
+		return nil
+	}
+	if ca, ok := a.(ContextAdapter); ok {
+		return ca
+	}
+	return &contextShim{a: a}
+}
+
+// contextShim implements ContextAdapter for an Adapter that does not take a context
+type contextShim struct {
+	a Adapter
+}
+
+func (s *contextShim) SetupContext(ctx context.Context) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+	return s.a.Setup()
+}
+
+func (s *contextShim) ListContext(ctx context.Context) ([]string, error) {
+	if err := ctxErr(ctx); err != nil {
+		return nil, err
+	}
+	return s.a.List()
+}
+
+func (s *contextShim) Begin(ctx context.Context) error {
+	return s.a.Begin(ctx)
+}
+
+func (s *contextShim) UpContext(ctx context.Context, name string, up, down io.Reader) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+	return s.a.Up(name, up, down)
+}
+
+func (s *contextShim) DownContext(ctx context.Context, name string) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+	return s.a.Down(name)
+}
+
+func (s *contextShim) Commit() error {
+	return s.a.Commit()
+}
+
+func (s *contextShim) Rollback() error {
+	return s.a.Rollback()
+}
+
+// rollbackReader returns the adapter as a ContextRollbackReader if it implements it or RollbackReader
+func (m *Migrate) rollbackReader() (ContextRollbackReader, bool) {
+	if rr, ok := capability[ContextRollbackReader](m); ok {
+		return rr, true
+	}
+	if rr, ok := capability[RollbackReader](m); ok {
+		return &rollbackReaderShim{rr: rr}, true
+	}
+	return nil, false
+}
+
+// rollbackReaderShim implements ContextRollbackReader for a RollbackReader
+type rollbackReaderShim struct {
+	rr RollbackReader
+}
+
+func (s *rollbackReaderShim) RollbackSQLContext(ctx context.Context, name string) (string, error) {
+	if err := ctxErr(ctx); err != nil {
+		return "", err
+	}
+	return s.rr.RollbackSQL(name)
+}
+
+// checksummer returns the adapter as a ContextChecksummer if it implements it or Checksummer
+func (m *Migrate) checksummer() (ContextChecksummer, bool) {
+	if c, ok := capability[ContextChecksummer](m); ok {
+		return c, true
+	}
+	if c, ok := capability[Checksummer](m); ok {
+		return &checksummerShim{c: c}, true
+	}
+	return nil, false
+}
+
+// checksummerShim implements ContextChecksummer for a Checksummer
+type checksummerShim struct {
+	c Checksummer
+}
+
+func (s *checksummerShim) ChecksumsContext(ctx context.Context) (map[string]string, error) {
+	if err := ctxErr(ctx); err != nil {
+		return nil, err
+	}
+	return s.c.Checksums()
+}
+
+func (s *checksummerShim) SetChecksumContext(ctx context.Context, name, checksum string) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
+	}
+	return s.c.SetChecksum(name, checksum)
+}
+
+// orBackground returns ctx, or context.Background() if ctx is nil
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// ctxErr returns the context's error, allowing for a nil context
+func ctxErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// capability returns the adapter as T if it implements the optional interface T
+func capability[T any](m *Migrate) (T, bool) {
+	t, ok := m.adapter.(T)
+	return t, ok
+}
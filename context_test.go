@@ -0,0 +1,195 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type MockContextAdapter struct {
+	MockAdapter
+}
+
+func (m *MockContextAdapter) SetupContext(ctx context.Context) error {
+	return m.Setup()
+}
+
+func (m *MockContextAdapter) ListContext(ctx context.Context) ([]string, error) {
+	return m.List()
+}
+
+func (m *MockContextAdapter) UpContext(ctx context.Context, name string, up, down io.Reader) error {
+	return m.Up(name, up, down)
+}
+
+func (m *MockContextAdapter) DownContext(ctx context.Context, name string) error {
+	return m.Down(name)
+}
+
+func TestAdaptContext(t *testing.T) {
+	if got := AdaptContext(nil); got != nil {
+		t.Errorf("AdaptContext(nil) = %v, want nil", got)
+	}
+
+	a := &MockAdapter{}
+	ca := AdaptContext(a)
+	if _, ok := ca.(*contextShim); !ok {
+		t.Errorf("AdaptContext() = %T, want *contextShim", ca)
+	}
+
+	both := &MockContextAdapter{}
+	if got := AdaptContext(both); got != both {
+		t.Errorf("AdaptContext() of a ContextAdapter = %v, want %v", got, both)
+	}
+}
+
+func TestContextShim(t *testing.T) {
+	a := &MockAdapter{applied: []string{"aaa"}}
+	ca := AdaptContext(a)
+	ctx := context.Background()
+
+	if err := ca.SetupContext(ctx); err != nil {
+		t.Errorf("SetupContext() unexpected error %v", err)
+	}
+	if got, err := ca.ListContext(ctx); err != nil || !reflect.DeepEqual(got, []string{"aaa"}) {
+		t.Errorf("ListContext() = %v, %v, want %v", got, err, []string{"aaa"})
+	}
+	if err := ca.Begin(ctx); err != nil {
+		t.Errorf("Begin() unexpected error %v", err)
+	}
+	if err := ca.UpContext(ctx, "bbb", bytes.NewBufferString("up bbb"), nil); err != nil {
+		t.Errorf("UpContext() unexpected error %v", err)
+	}
+	if err := ca.DownContext(ctx, "aaa"); err != nil {
+		t.Errorf("DownContext() unexpected error %v", err)
+	}
+	if err := ca.Rollback(); err != nil {
+		t.Errorf("Rollback() unexpected error %v", err)
+	}
+	if err := ca.Commit(); err != nil {
+		t.Errorf("Commit() unexpected error %v", err)
+	}
+	wantCalls := []string{"begin", "up bbb", "down aaa", "rollback", "commit"}
+	if !reflect.DeepEqual(a.calls, wantCalls) {
+		t.Errorf("calls = %v, want %v", a.calls, wantCalls)
+	}
+}
+
+func TestContextShim_WithCancelledContext(t *testing.T) {
+	a := &MockAdapter{}
+	ca := AdaptContext(a)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := ca.SetupContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("SetupContext() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := ca.ListContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ListContext() error = %v, want %v", err, context.Canceled)
+	}
+	if err := ca.UpContext(ctx, "aaa", bytes.NewBufferString("up aaa"), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("UpContext() error = %v, want %v", err, context.Canceled)
+	}
+	if err := ca.DownContext(ctx, "aaa"); !errors.Is(err, context.Canceled) {
+		t.Errorf("DownContext() error = %v, want %v", err, context.Canceled)
+	}
+	if len(a.calls) != 0 {
+		t.Errorf("calls = %v, want none", a.calls)
+	}
+}
+
+func TestMigrate_Migrate_WithContextAdapter(t *testing.T) {
+	a := &MockAdapter{}
+	m := New(WithContextAdapter(AdaptContext(a)), WithProvider(&MockProvider{names: []string{"aaa"}}))
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Errorf("Migrate() unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(a.applied, []string{"aaa"}) {
+		t.Errorf("Migrate() applied = %v, want %v", a.applied, []string{"aaa"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m = New(WithAdapter(&MockAdapter{}), WithProvider(&MockProvider{names: []string{"aaa"}}))
+	if err := m.Migrate(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Migrate() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+type MockContextChecksumAdapter struct {
+	MockChecksumAdapter
+	ctxs []context.Context
+}
+
+func (m *MockContextChecksumAdapter) ChecksumsContext(ctx context.Context) (map[string]string, error) {
+	m.ctxs = append(m.ctxs, ctx)
+	return m.Checksums()
+}
+
+func (m *MockContextChecksumAdapter) SetChecksumContext(ctx context.Context, name, sum string) error {
+	m.ctxs = append(m.ctxs, ctx)
+	return m.SetChecksum(name, sum)
+}
+
+func (m *MockContextChecksumAdapter) RollbackSQLContext(ctx context.Context, name string) (string, error) {
+	m.ctxs = append(m.ctxs, ctx)
+	return m.RollbackSQL(name)
+}
+
+type ctxKey struct{}
+
+func TestMigrate_WithContextChecksummer(t *testing.T) {
+	a := &MockContextChecksumAdapter{MockChecksumAdapter: MockChecksumAdapter{
+		MockAdapter: MockAdapter{applied: []string{"aaa", "bbb"}},
+		checksums:   map[string]string{"aaa": mockSum("aaa")},
+	}}
+	m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa"}}), WithMissingPolicy(MissingRollback))
+	ctx := context.WithValue(context.Background(), ctxKey{}, "run")
+
+	if _, err := m.Plan(ctx); err != nil {
+		t.Fatalf("Plan() unexpected error %v", err)
+	}
+	if err := m.Rebaseline(ctx, "aaa"); err != nil {
+		t.Fatalf("Rebaseline() unexpected error %v", err)
+	}
+	// Plan reads checksums and the rollback of bbb, Rebaseline sets the checksum of aaa
+	if len(a.ctxs) != 3 {
+		t.Fatalf("context methods called %d times, want %d", len(a.ctxs), 3)
+	}
+	for _, got := range a.ctxs {
+		if got.Value(ctxKey{}) != "run" {
+			t.Errorf("context method called with %v, want the caller's context", got)
+		}
+	}
+}
+
+func TestMigrate_checksummer_WithCancelledContext(t *testing.T) {
+	a := &MockChecksumAdapter{MockAdapter: MockAdapter{}, checksums: map[string]string{}}
+	m := New(WithAdapter(a))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c, ok := m.checksummer()
+	if !ok {
+		t.Fatalf("checksummer() ok = %v, want %v", ok, true)
+	}
+	if _, err := c.ChecksumsContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ChecksumsContext() error = %v, want %v", err, context.Canceled)
+	}
+	if err := c.SetChecksumContext(ctx, "aaa", "sum"); !errors.Is(err, context.Canceled) {
+		t.Errorf("SetChecksumContext() error = %v, want %v", err, context.Canceled)
+	}
+	rr, ok := m.rollbackReader()
+	if !ok {
+		t.Fatalf("rollbackReader() ok = %v, want %v", ok, true)
+	}
+	if _, err := rr.RollbackSQLContext(ctx, "aaa"); !errors.Is(err, context.Canceled) {
+		t.Errorf("RollbackSQLContext() error = %v, want %v", err, context.Canceled)
+	}
+	if len(a.checksums) != 0 {
+		t.Errorf("checksums = %v, want none", a.checksums)
+	}
+}
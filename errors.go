@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MissingMigrationsError is returned when applied migrations are no longer known to the provider and the
+// MissingPolicy does not allow them to be taken down
+type MissingMigrationsError struct {
+	// Names of the applied migrations that would have been taken down
+	Names []string
+}
+
+func (e *MissingMigrationsError) Error() string {
+	return fmt.Sprintf("applied migrations missing from provider: %s", strings.Join(e.Names, ", "))
+}
+
+// ChecksumMismatchError is returned when the up script of applied migrations changed since they were applied
+type ChecksumMismatchError struct {
+	// Names of the applied migrations that changed
+	Names []string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("applied migrations changed since they were applied: %s", strings.Join(e.Names, ", "))
+}
+
+// ErrLocked is returned when the migration lock could not be acquired because it is held by another migrator
+var ErrLocked = errors.New("migration lock held by another migrator")
+
+// IncompleteError is returned when a run fails after some migrations were committed, because it used TxPerMigration
+// or TxNone, or because the adapter committed them itself
+type IncompleteError struct {
+	// Committed lists the migrations taken down or applied before the failure, in the order they were committed
+	Committed []string
+	// Err is what caused the run to fail
+	Err error
+}
+
+func (e *IncompleteError) Error() string {
+	return fmt.Sprintf("%v (committed before failing: %s)", e.Err, strings.Join(e.Committed, ", "))
+}
+
+func (e *IncompleteError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when a migration, or one of its statements, runs for longer than allowed
+type TimeoutError struct {
+	// Migration that timed out
+	Migration string
+	// Statement that was running when the timeout was exceeded, if known
+	Statement string
+	// Timeout that was exceeded, if known
+	Timeout time.Duration
+	// Err is the error returned when the timeout was exceeded
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := "migration"
+	if e.Migration != "" {
+		msg += fmt.Sprintf(" '%v'", e.Migration)
+	}
+	msg += " timed out"
+	if e.Timeout > 0 {
+		msg += fmt.Sprintf(" after %v", e.Timeout)
+	}
+	if e.Statement != "" {
+		msg += fmt.Sprintf(" running statement '%v'", e.Statement)
+	}
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,51 @@
+// Command files_to_progress applies the migration files in ./migrations with the postgres adapter, rendering a
+// progress bar for each migration as its statements are executed.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/mertenvg/migrate"
+	"github.com/mertenvg/migrate/adapter/postgres"
+	"github.com/mertenvg/migrate/adapter/sqldb"
+	"github.com/mertenvg/migrate/provider/files"
+)
+
+func main() {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	p, err := files.Load("./migrations")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	a := postgres.NewAdapter(db, sqldb.WithProgress(func(p sqldb.Progress) {
+		const width = 40
+		done := width * p.Statement / p.Total
+		fmt.Printf("\r%-4s %-40s [%s%s] %d/%d %v",
+			p.Direction, p.Migration,
+			strings.Repeat("=", done), strings.Repeat(" ", width-done),
+			p.Statement, p.Total, p.Elapsed.Round(time.Millisecond),
+		)
+		if p.Statement == p.Total {
+			fmt.Println()
+		}
+	}))
+
+	m := migrate.New(migrate.WithProvider(p), migrate.WithAdapter(a))
+	if err := m.Migrate(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
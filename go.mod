@@ -2,4 +2,7 @@ module github.com/mertenvg/migrate
 
 go 1.24.1
 
-require github.com/DATA-DOG/go-sqlmock v1.5.2 // indirect
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/lib/pq v1.10.9
+)
@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Direction tells whether a migration is being applied or taken down
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Event describes what a Hook is called for. Name and Direction are empty for the run hooks, except for the on
+// failure hooks when the failure was caused by a migration.
+type Event struct {
+	// Name of the migration
+	Name string
+	// Direction the migration is run in
+	Direction Direction
+	// Duration of the migration or run, 0 for the before hooks
+	Duration time.Duration
+	// Err the run failed with, only set for the on failure hooks
+	Err error
+}
+
+// Hook is called around a run or a migration. Returning an error aborts the run, rolling back the current
+// transaction, except from the after run and on failure hooks which can only add their error to the result.
+type Hook func(ctx context.Context, e Event) error
+
+type hooks struct {
+	beforeRun       []Hook
+	afterRun        []Hook
+	beforeMigration []Hook
+	afterMigration  []Hook
+	onFailure       []Hook
+}
+
+// apply takes down or applies the step's migration, calling the migration hooks around it. The hooks run in the
+// migration's transaction, if it has one, so they are called again if the transaction is retried.
+func (m *Migrate) apply(ctx context.Context, s *state, st step) error {
+	e := Event{Name: st.name, Direction: st.direction()}
+	if err := m.fire(ctx, "before migration", m.hooks.beforeMigration, e); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err := m.exec(ctx, s, st)
+	e.Duration = time.Since(start)
+	attrs := []slog.Attr{
+		slog.String("migration", st.name),
+		slog.String("direction", string(e.Direction)),
+		slog.Duration("duration", e.Duration),
+	}
+	if err != nil {
+		m.logAttrs(ctx, slog.LevelError, "migration failed", append(attrs, slog.Any("error", err))...)
+		return err
+	}
+	m.logAttrs(ctx, slog.LevelInfo, "migration done", attrs...)
+
+	return m.fire(ctx, "after migration", m.hooks.afterMigration, e)
+}
+
+// fire calls the hooks in the order they were registered, stopping at the first error
+func (m *Migrate) fire(ctx context.Context, kind string, hooks []Hook, e Event) error {
+	for _, hook := range hooks {
+		if err := hook(ctx, e); err != nil {
+			if e.Name != "" {
+				return fmt.Errorf("%s hook failed for migration '%v': %w", kind, e.Name, err)
+			}
+			return fmt.Errorf("%s hook failed: %w", kind, err)
+		}
+	}
+	return nil
+}
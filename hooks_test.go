@@ -0,0 +1,158 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordHooks returns options registering every hook, recording the events they are called with in events
+func recordHooks(events *[]string, fail string) []Option {
+	record := func(kind string) Hook {
+		return func(ctx context.Context, e Event) error {
+			event := kind
+			if e.Name != "" {
+				event += fmt.Sprintf(" %s %s", e.Direction, e.Name)
+			}
+			if e.Err != nil {
+				event += ": " + e.Err.Error()
+			}
+			*events = append(*events, event)
+			if event == fail {
+				return errors.New("hook failed")
+			}
+			return nil
+		}
+	}
+	return []Option{
+		WithBeforeRun(record("before run")),
+		WithAfterRun(record("after run")),
+		WithBeforeMigration(record("before")),
+		WithAfterMigration(record("after")),
+		WithOnFailure(record("failure")),
+	}
+}
+
+func TestMigrate_Migrate_WithHooks(t *testing.T) {
+	tests := []struct {
+		name       string
+		applied    []string
+		failUp     string
+		failHook   string
+		wantErr    bool
+		wantEvents []string
+		wantCalls  []string
+	}{
+		{
+			name:    "successful run",
+			applied: []string{"aaa", "ccc"},
+			wantEvents: []string{
+				"before run",
+				"before down ccc", "after down ccc",
+				"before up bbb", "after up bbb",
+				"after run",
+			},
+			wantCalls: []string{"begin", "down ccc", "up bbb", "commit"},
+		},
+		{
+			name:    "failed migration",
+			applied: []string{"aaa"},
+			failUp:  "bbb",
+			wantErr: true,
+			wantEvents: []string{
+				"before run",
+				"before up bbb",
+				"failure up bbb: failed to apply migration 'bbb': fail up bbb",
+			},
+			wantCalls: []string{"begin", "up bbb", "rollback"},
+		},
+		{
+			name:     "before run hook aborts",
+			applied:  []string{"aaa"},
+			failHook: "before run",
+			wantErr:  true,
+			wantEvents: []string{
+				"before run",
+			},
+		},
+		{
+			name:     "before migration hook aborts",
+			applied:  []string{"aaa"},
+			failHook: "before up bbb",
+			wantErr:  true,
+			wantEvents: []string{
+				"before run",
+				"before up bbb",
+				"failure up bbb: before migration hook failed for migration 'bbb': hook failed",
+			},
+			wantCalls: []string{"begin", "rollback"},
+		},
+		{
+			name:     "after migration hook aborts",
+			applied:  []string{"aaa"},
+			failHook: "after up bbb",
+			wantErr:  true,
+			wantEvents: []string{
+				"before run",
+				"before up bbb", "after up bbb",
+				"failure up bbb: after migration hook failed for migration 'bbb': hook failed",
+			},
+			wantCalls: []string{"begin", "up bbb", "rollback"},
+		},
+		{
+			name:     "after run hook error",
+			applied:  []string{"aaa"},
+			failHook: "after run",
+			wantErr:  true,
+			wantEvents: []string{
+				"before run",
+				"before up bbb", "after up bbb",
+				"after run",
+			},
+			wantCalls: []string{"begin", "up bbb", "commit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+			a := &MockAdapter{applied: tt.applied, failUp: tt.failUp}
+			opts := append([]Option{
+				WithAdapter(a),
+				WithProvider(&MockProvider{names: []string{"aaa", "bbb"}}),
+				WithMissingPolicy(MissingRollback),
+			}, recordHooks(&events, tt.failHook)...)
+			m := New(opts...)
+
+			err := m.Migrate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("Migrate() events = %q, want %q", events, tt.wantEvents)
+			}
+			if !reflect.DeepEqual(a.calls, tt.wantCalls) {
+				t.Errorf("Migrate() calls = %v, want %v", a.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMigrate_Migrate_WithFailingFailureHook(t *testing.T) {
+	hookErr := errors.New("deploy log unavailable")
+	a := &MockAdapter{failUp: "aaa"}
+	m := New(
+		WithAdapter(a),
+		WithProvider(&MockProvider{names: []string{"aaa"}}),
+		WithOnFailure(func(ctx context.Context, e Event) error {
+			return hookErr
+		}),
+	)
+
+	err := m.Migrate(context.Background())
+	if !errors.Is(err, hookErr) || !strings.Contains(err.Error(), "fail up aaa") {
+		t.Errorf("Migrate() error = %v, want it to include the migration and hook errors", err)
+	}
+}
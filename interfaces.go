@@ -2,7 +2,9 @@ package migrate
 
 import (
 	"context"
+	"database/sql"
 	"io"
+	"time"
 )
 
 // Adapter applies the necessary migrations to the database
@@ -23,6 +25,26 @@ type Adapter interface {
 	Rollback() error
 }
 
+// ContextAdapter is an Adapter that receives a context.Context on every call, so cancellation and deadlines reach
+// every query. Use AdaptContext to turn an Adapter into a ContextAdapter.
+type ContextAdapter interface {
+	// SetupContext must set up the migration store to record which migrations have been commited to the db
+	SetupContext(ctx context.Context) error
+	// ListContext lists the applied migrations
+	ListContext(ctx context.Context) ([]string, error)
+	// Begin a transaction
+	Begin(ctx context.Context) error
+	// UpContext applies a migration
+	UpContext(ctx context.Context, name string, up, down io.Reader) error
+	// DownContext takes down a migration that was previously applied, if it has a down/rollback saved when it was
+	// applied
+	DownContext(ctx context.Context, name string) error
+	// Commit the transaction
+	Commit() error
+	// Rollback the transaction
+	Rollback() error
+}
+
 // Provider tells us what needs to be done
 type Provider interface {
 	// Next should return the next Migration or nil if there isn't one. error is reserved for actual errors.
@@ -33,10 +55,113 @@ type Provider interface {
 type Migration interface {
 	// Name of the migration, must be unique to avoid migration conflicts
 	Name() string
-	// Up returns what needs to be applied as an io.Reader
+	// Up returns what needs to be applied as an io.Reader. It may be called several times, for checksums, plans and
+	// retries, and must return a new reader positioned at the start of the script on every call.
 	Up() io.Reader
-	// Down returns what will need to be rolled back as an io.Reader
+	// Down returns what will need to be rolled back as an io.Reader. Like Up, it must return a new reader on every call.
 	Down() io.Reader
 	// Close any open readers or files
 	Close()
 }
+
+// RollbackReader can optionally be implemented by an Adapter to expose the rollback stored with an applied migration
+type RollbackReader interface {
+	// RollbackSQL returns the rollback saved when the named migration was applied
+	RollbackSQL(name string) (string, error)
+}
+
+// Checksummer can optionally be implemented by an Adapter that records a checksum of each migration's up script when
+// it is applied, so changes to applied migrations can be detected
+type Checksummer interface {
+	// Checksums returns the recorded checksum of each applied migration, empty if none was recorded
+	Checksums() (map[string]string, error)
+	// SetChecksum replaces the recorded checksum of an applied migration
+	SetChecksum(name, checksum string) error
+}
+
+// ContextRollbackReader is a RollbackReader receiving a context.Context, used instead of RollbackReader when an
+// Adapter implements both
+type ContextRollbackReader interface {
+	// RollbackSQLContext returns the rollback saved when the named migration was applied
+	RollbackSQLContext(ctx context.Context, name string) (string, error)
+}
+
+// ContextChecksummer is a Checksummer receiving a context.Context, used instead of Checksummer when an Adapter
+// implements both
+type ContextChecksummer interface {
+	// ChecksumsContext returns the recorded checksum of each applied migration, empty if none was recorded
+	ChecksumsContext(ctx context.Context) (map[string]string, error)
+	// SetChecksumContext replaces the recorded checksum of an applied migration
+	SetChecksumContext(ctx context.Context, name, checksum string) error
+}
+
+// Inspector can optionally be implemented by an Adapter able to read the applied migrations without creating the store
+// they are recorded in, so Plan, Status and Verify leave the database as it is. Setup is called instead otherwise.
+type Inspector interface {
+	// InspectContext prepares the adapter to list the applied migrations, returning false without an error if the
+	// store does not exist, as nothing was ever applied
+	InspectContext(ctx context.Context) (bool, error)
+}
+
+// AppliedLister can optionally be implemented by an Adapter that records when each migration was applied, so Rollback,
+// DownTo and Redo take down the most recently applied migrations rather than those last in name order
+type AppliedLister interface {
+	// ListAppliedContext lists the applied migrations in the order they were applied, oldest first
+	ListAppliedContext(ctx context.Context) ([]string, error)
+}
+
+// Locker can optionally be implemented by an Adapter to stop several migrators from running at the same time
+type Locker interface {
+	// Lock waits until the migration lock is acquired or ctx is done
+	Lock(ctx context.Context) error
+	// Unlock releases the migration lock
+	Unlock() error
+}
+
+// NonTransactional can optionally be implemented by a Migration that must not run inside a transaction, such as one
+// using CREATE INDEX CONCURRENTLY
+type NonTransactional interface {
+	// NoTransaction returns true if the migration must run outside a transaction
+	NoTransaction() bool
+}
+
+// MigrationFunc is a migration step written in Go, run inside the adapter's transaction
+type MigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+// FuncMigration can optionally be implemented by a Migration written in Go instead of SQL
+type FuncMigration interface {
+	// UpFunc returns the function applying the migration
+	UpFunc() MigrationFunc
+	// DownFunc returns the function taking the migration down, or nil if it cannot be taken down
+	DownFunc() MigrationFunc
+}
+
+// FuncAdapter can optionally be implemented by an Adapter able to run a FuncMigration
+type FuncAdapter interface {
+	// UpFunc applies a migration by calling up with the adapter's transaction
+	UpFunc(ctx context.Context, name string, up func(context.Context, *sql.Tx) error) error
+	// DownFunc takes down a migration by calling down, if not nil, with the adapter's transaction
+	DownFunc(ctx context.Context, name string, down func(context.Context, *sql.Tx) error) error
+}
+
+// Opener can optionally be implemented by a Migration to report errors opening its up and down scripts when they are
+// requested, rather than when they are read
+type Opener interface {
+	// OpenUp returns what needs to be applied as an io.Reader, a new one on every call like Migration.Up
+	OpenUp() (io.Reader, error)
+	// OpenDown returns what will need to be rolled back as an io.Reader, a new one on every call like Migration.Down
+	OpenDown() (io.Reader, error)
+}
+
+// StatementTimeouter can optionally be implemented by an Adapter able to limit how long each statement may run
+type StatementTimeouter interface {
+	// SetStatementTimeout limits how long each statement may run, 0 removes the limit
+	SetStatementTimeout(d time.Duration)
+}
+
+// RetryClassifier can optionally be implemented by an Adapter able to tell which errors are worth retrying, such as
+// lock timeouts and serialization failures
+type RetryClassifier interface {
+	// Retryable returns true if the transaction that failed with err could succeed if run again
+	Retryable(err error) bool
+}
@@ -2,13 +2,57 @@ package migrate
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"slices"
+	"time"
+)
+
+// MissingPolicy decides what happens to applied migrations that are no longer known to the provider
+type MissingPolicy int
+
+const (
+	// MissingError fails before anything is changed, returning a *MissingMigrationsError
+	MissingError MissingPolicy = iota
+	// MissingSkip logs the missing migrations and leaves them applied
+	MissingSkip
+	// MissingRollback takes the missing migrations down using the rollback saved when they were applied
+	MissingRollback
+)
+
+// TxMode decides how migrations are grouped into transactions
+type TxMode int
+
+const (
+	// TxSingle runs every migration in a single transaction, nothing is committed unless all of them succeed. Migrations
+	// implementing NonTransactional are the exception, the transaction is committed before they run outside of it.
+	TxSingle TxMode = iota
+	// TxPerMigration runs each migration in its own transaction, committing it before moving on to the next
+	TxPerMigration
+	// TxNone runs migrations without a transaction
+	TxNone
 )
 
 type Migrate struct {
-	a Adapter
-	p Provider
+	a ContextAdapter
+	// adapter is the adapter as provided, checked for the optional interfaces it implements
+	adapter  any
+	p        Provider
+	logger   *slog.Logger
+	missing  MissingPolicy
+	checksum ChecksumPolicy
+
+	lockTimeout      time.Duration
+	txMode           TxMode
+	migrationTimeout time.Duration
+	statementTimeout time.Duration
+	retry            RetryPolicy
+	hooks            hooks
+
+	// loaded holds the migrations read from the provider so Plan and Migrate can be called on the same instance
+	loaded *loaded
 }
 
 type Option func(m *Migrate)
@@ -21,80 +65,480 @@ func New(opts ...Option) *Migrate {
 	return m
 }
 
-func (m *Migrate) Migrate(ctx context.Context) error {
+// loaded is the ordered set of migrations returned by the provider
+type loaded struct {
+	names      []string
+	migrations map[string]Migration
+}
+
+// load reads every migration from the provider once and keeps them for subsequent calls
+func (m *Migrate) load() (*loaded, error) {
+	if m.loaded != nil {
+		return m.loaded, nil
+	}
+	l := &loaded{
+		migrations: map[string]Migration{},
+	}
+	for {
+		migration, err := m.p.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get migrations: %w", err)
+		}
+		if migration == nil {
+			break
+		}
+		l.names = append(l.names, migration.Name())
+		l.migrations[migration.Name()] = migration
+	}
+	m.loaded = l
+	return l, nil
+}
+
+// diff compares the provider's migrations with the applied ones and returns what needs to be taken down and what
+// needs to be applied, in the order it should happen
+func diff(l *loaded, applied []string) (down []string, up []string) {
+	for _, name := range applied {
+		if _, ok := l.migrations[name]; ok {
+			// migration files are still there, leave it alone
+			continue
+		}
+		down = append(down, name)
+	}
+	for _, name := range l.names {
+		if slices.Contains(applied, name) {
+			// this migration is already applied, we can skip it
+			continue
+		}
+		up = append(up, name)
+	}
+	return down, up
+}
+
+// state is the result of comparing the provider's migrations with the applied ones
+type state struct {
+	*loaded
+	// applied migrations as listed by the adapter
+	applied []string
+	// order lists the applied migrations in the order they were applied if the adapter is an AppliedLister, and like
+	// applied otherwise
+	order []string
+	// down lists the applied migrations to take down
+	down []string
+	// up lists the migrations to apply
+	up []string
+}
+
+// validate makes sure an adapter and provider have been configured
+func (m *Migrate) validate() error {
 	if m.a == nil {
 		return fmt.Errorf("no adapter provided")
 	}
 	if m.p == nil {
 		return fmt.Errorf("no provider provided")
 	}
+	return nil
+}
+
+// prepare validates the configuration, sets up the adapter and works out what needs to be done
+func (m *Migrate) prepare(ctx context.Context) (*state, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
 
 	// make sure adapter is initiated
-	if err := m.a.Setup(); err != nil {
-		return fmt.Errorf("setup failed: %w", err)
+	if err := m.a.SetupContext(ctx); err != nil {
+		return nil, fmt.Errorf("setup failed: %w", err)
+	}
+	if st, ok := capability[StatementTimeouter](m); ok && m.statementTimeout > 0 {
+		st.SetStatementTimeout(m.statementTimeout)
 	}
+	return m.read(ctx, true)
+}
 
+// inspect works out what needs to be done like prepare, without setting up the adapter if it is an Inspector, so
+// nothing is changed. Nothing is applied if the adapter's store does not exist.
+func (m *Migrate) inspect(ctx context.Context) (*state, error) {
+	in, ok := capability[Inspector](m)
+	if !ok {
+		return m.prepare(ctx)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	exists, err := in.InspectContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("inspect failed: %w", err)
+	}
+	return m.read(ctx, exists)
+}
+
+// read compares the provider's migrations with the applied ones, which are only listed if the adapter's store exists
+func (m *Migrate) read(ctx context.Context, exists bool) (*state, error) {
 	// get list of migration files from provider
-	var names []string
-	migrations := map[string]Migration{}
-	for {
-		migration, err := m.p.Next()
-		if migration == nil {
-			break
+	l, err := m.load()
+	if err != nil {
+		return nil, err
+	}
+	s := &state{loaded: l}
+	if exists {
+		// get list of applied migrations
+		if s.applied, err = m.a.ListContext(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get applied migrations: %w", err)
 		}
-		if err != nil {
-			return fmt.Errorf("failed to get migrations: %w", err)
+		s.order = s.applied
+		if al, ok := capability[AppliedLister](m); ok {
+			if s.order, err = al.ListAppliedContext(ctx); err != nil {
+				return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+			}
 		}
-		names = append(names, migration.Name())
-		migrations[migration.Name()] = migration
 	}
+	s.down, s.up = diff(l, s.applied)
+	return s, nil
+}
+
+// resolveMissing applies the MissingPolicy to the applied migrations no longer known to the provider
+func (m *Migrate) resolveMissing(s *state) error {
+	if len(s.down) == 0 {
+		return nil
+	}
+	switch m.missing {
+	case MissingRollback:
+		return nil
+	case MissingSkip:
+		m.logAttrs(context.Background(), slog.LevelWarn, "skipping applied migrations missing from provider", slog.Any("migrations", s.down))
+		s.down = nil
+		return nil
+	default:
+		return &MissingMigrationsError{Names: s.down}
+	}
+}
 
-	// get list of applied migrations
-	applied, err := m.a.List()
+// logAttrs logs a structured record if a logger was set with WithLogger
+func (m *Migrate) logAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if m.logger != nil {
+		m.logger.LogAttrs(orBackground(ctx), level, msg, attrs...)
+	}
+}
+
+// lock acquires the migration lock if the adapter supports it. The returned func releases it.
+func (m *Migrate) lock(ctx context.Context) (func(), error) {
+	l, ok := capability[Locker](m)
+	if !ok {
+		return func() {}, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if m.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.lockTimeout)
+		defer cancel()
+	}
+	if err := l.Lock(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %w", ErrLocked, err)
+		}
+		return nil, fmt.Errorf("lock failed: %w", err)
+	}
+	return func() {
+		if err := l.Unlock(); err != nil {
+			m.logAttrs(context.Background(), slog.LevelError, "failed to release migration lock", slog.Any("error", err))
+		}
+	}, nil
+}
+
+func (m *Migrate) Migrate(ctx context.Context) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get applied migrations: %w", err)
+		return err
 	}
+	defer unlock()
 
-	// start the transaction
-	if err := m.a.Begin(ctx); err != nil {
-		return fmt.Errorf("begin transaction failed: %w", err)
+	s, err := m.prepare(ctx)
+	if err != nil {
+		return err
 	}
+	if err := m.resolveMissing(s); err != nil {
+		return err
+	}
+	if err := m.verifyChecksums(ctx, s); err != nil {
+		return err
+	}
+	return m.run(ctx, s, s.down, s.up)
+}
 
-	// take down migration no longer available
-	for _, name := range applied {
-		_, ok := migrations[name]
-		if ok {
-			// migration files are still there, leave it alone
-			continue
+// Verify checks the applied migrations against the provider without changing anything, not even creating the adapter's
+// store if it is an Inspector. It fails the way Migrate would before running, with a *MissingMigrationsError under
+// MissingError or a *ChecksumMismatchError under ChecksumError.
+func (m *Migrate) Verify(ctx context.Context) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	s, err := m.inspect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.resolveMissing(s); err != nil {
+		return err
+	}
+	return m.verifyChecksums(ctx, s)
+}
+
+// step is a single migration to take down or apply
+type step struct {
+	name string
+	down bool
+}
+
+func (st step) direction() Direction {
+	if st.down {
+		return DirectionDown
+	}
+	return DirectionUp
+}
+
+// run takes down and applies the named migrations, calling the run hooks around them
+func (m *Migrate) run(ctx context.Context, s *state, down, up []string) error {
+	start := time.Now()
+	if err := m.fire(ctx, "before run", m.hooks.beforeRun, Event{}); err != nil {
+		return err
+	}
+	m.logAttrs(ctx, slog.LevelInfo, "starting migration run", slog.Int("down", len(down)), slog.Int("up", len(up)))
+
+	failed, err := m.runGroups(ctx, s, down, up)
+	if err != nil {
+		e := Event{Duration: time.Since(start), Err: err}
+		if failed != nil {
+			e.Name, e.Direction = failed.name, failed.direction()
 		}
-		err := m.a.Down(name)
-		if err != nil {
-			return fmt.Errorf("failed to take down migration '%v': %w, %w", name, err, m.a.Rollback())
+		m.logAttrs(ctx, slog.LevelError, "migration run failed", slog.Duration("duration", e.Duration), slog.Any("error", err))
+		if herr := m.fire(ctx, "on failure", m.hooks.onFailure, e); herr != nil {
+			return errors.Join(err, herr)
 		}
+		return err
 	}
 
-	// apply new migrations
-	for _, name := range names {
-		migration, ok := migrations[name]
-		if !ok || migration == nil {
-			// if this is missing there's something wrong
-			return fmt.Errorf("failed to find migration '%v': %w, %w", name, err, m.a.Rollback())
+	duration := time.Since(start)
+	m.logAttrs(ctx, slog.LevelInfo, "finished migration run", slog.Duration("duration", duration))
+	return m.fire(ctx, "after run", m.hooks.afterRun, Event{Duration: duration})
+}
+
+// runGroups takes down and applies the named migrations, grouping them into transactions according to the TxMode. If
+// a group fails, the groups before it stay committed and are listed in the returned *IncompleteError. The step that
+// failed is returned along with the error, or nil if the failure was not caused by a migration.
+func (m *Migrate) runGroups(ctx context.Context, s *state, down, up []string) (*step, error) {
+	var committed []string
+	for _, group := range m.group(s, down, up) {
+		var failed *step
+		noTx := m.noTransaction(s, group)
+		attempt := func() error {
+			failed = nil
+			return m.inTx(ctx, noTx, func() error {
+				for _, st := range group {
+					if err := m.apply(ctx, s, st); err != nil {
+						failed = &st
+						return err
+					}
+				}
+				return nil
+			})
 		}
-		if slices.Contains(applied, migration.Name()) {
-			// this migration is already applied, we can skip it
-			continue
+		var err error
+		if noTx {
+			// statements applied before the failure stay applied, so running the group again is not safe
+			err = attempt()
+		} else {
+			err = m.withRetry(ctx, attempt)
 		}
-		err := m.a.Up(migration.Name(), migration.Up(), migration.Down())
 		if err != nil {
-			return fmt.Errorf("failed to apply migration '%v': %w, %w", name, err, m.a.Rollback())
+			if len(committed) == 0 {
+				return failed, err
+			}
+			// an adapter committing part of the group reports it, list the groups committed before it first
+			var ie *IncompleteError
+			if errors.As(err, &ie) {
+				ie.Committed = append(committed, ie.Committed...)
+				return failed, err
+			}
+			return failed, &IncompleteError{Committed: committed, Err: err}
+		}
+		for _, st := range group {
+			committed = append(committed, st.name)
+		}
+	}
+	return nil, nil
+}
+
+// group splits the steps into the groups that are committed together. With TxSingle every step is in one group,
+// except for migrations that must not run in a transaction which get a group of their own.
+func (m *Migrate) group(s *state, down, up []string) [][]step {
+	var steps []step
+	for _, name := range down {
+		steps = append(steps, step{name: name, down: true})
+	}
+	for _, name := range up {
+		steps = append(steps, step{name: name})
+	}
+
+	var groups [][]step
+	var current []step
+	for _, st := range steps {
+		if m.txMode == TxSingle && !m.noTransaction(s, []step{st}) {
+			current = append(current, st)
+			continue
+		}
+		if len(current) > 0 {
+			groups = append(groups, current)
+			current = nil
+		}
+		groups = append(groups, []step{st})
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+// noTransaction returns true if the group must run without a transaction
+func (m *Migrate) noTransaction(s *state, group []step) bool {
+	if m.txMode == TxNone {
+		return true
+	}
+	if len(group) != 1 {
+		return false
+	}
+	nt, ok := s.migrations[group[0].name].(NonTransactional)
+	return ok && nt.NoTransaction()
+}
+
+// inTx runs f in a transaction, rolling back if it fails, or runs it without a transaction if noTx is true
+func (m *Migrate) inTx(ctx context.Context, noTx bool, f func() error) error {
+	if noTx {
+		return f()
+	}
+
+	// start the transaction
+	if err := m.a.Begin(ctx); err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+
+	if err := f(); err != nil {
+		if rbErr := m.a.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("rollback transaction failed: %w", rbErr))
 		}
-		migration.Close()
+		return err
 	}
 
 	// commit the changes
 	if err := m.a.Commit(); err != nil {
 		return fmt.Errorf("commit transaction failed: %w", err)
 	}
+	return nil
+}
+
+// exec takes down or applies the step's migration
+func (m *Migrate) exec(ctx context.Context, s *state, st step) error {
+	if m.migrationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(orBackground(ctx), m.migrationTimeout)
+		defer cancel()
+	}
 
+	var err error
+	if st.down {
+		err = m.down(ctx, s, st.name)
+	} else {
+		err = m.up(ctx, s, st.name)
+	}
+	if err == nil {
+		return nil
+	}
+
+	// name the migration in timeouts reported by the adapter, or report the migration timeout
+	var te *TimeoutError
+	if errors.As(err, &te) {
+		te.Migration = st.name
+		if te.Timeout == 0 && ctxErr(ctx) != nil {
+			te.Timeout = m.migrationTimeout
+		}
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) && m.migrationTimeout > 0 && ctxErr(ctx) != nil {
+		return &TimeoutError{Migration: st.name, Timeout: m.migrationTimeout, Err: err}
+	}
+	return err
+}
+
+// down takes down the named applied migration
+func (m *Migrate) down(ctx context.Context, s *state, name string) error {
+	if fm, ok := s.migrations[name].(FuncMigration); ok {
+		fa, ok := capability[FuncAdapter](m)
+		if !ok {
+			return fmt.Errorf("failed to take down migration '%v': adapter cannot run Go migrations", name)
+		}
+		if err := fa.DownFunc(ctx, name, fm.DownFunc()); err != nil {
+			return fmt.Errorf("failed to take down migration '%v': %w", name, err)
+		}
+		return nil
+	}
+	if err := m.a.DownContext(ctx, name); err != nil {
+		return fmt.Errorf("failed to take down migration '%v': %w", name, err)
+	}
 	return nil
 }
+
+// up applies the named migration
+func (m *Migrate) up(ctx context.Context, s *state, name string) error {
+	migration, ok := s.migrations[name]
+	if !ok || migration == nil {
+		// if this is missing there's something wrong
+		return fmt.Errorf("failed to find migration '%v'", name)
+	}
+	defer migration.Close()
+	if fm, ok := migration.(FuncMigration); ok {
+		fa, ok := capability[FuncAdapter](m)
+		if !ok {
+			return fmt.Errorf("failed to apply migration '%v': adapter cannot run Go migrations", name)
+		}
+		if err := fa.UpFunc(ctx, name, fm.UpFunc()); err != nil {
+			return fmt.Errorf("failed to apply migration '%v': %w", name, err)
+		}
+		return nil
+	}
+	up, down, err := open(migration)
+	if err != nil {
+		return fmt.Errorf("failed to open migration '%v': %w", name, err)
+	}
+	if err := m.a.UpContext(ctx, migration.Name(), up, down); err != nil {
+		return fmt.Errorf("failed to apply migration '%v': %w", name, err)
+	}
+	return nil
+}
+
+// open returns the up and down scripts of the migration, reporting errors opening them if it implements Opener
+func open(migration Migration) (io.Reader, io.Reader, error) {
+	o, ok := migration.(Opener)
+	if !ok {
+		return migration.Up(), migration.Down(), nil
+	}
+	up, err := o.OpenUp()
+	if err != nil {
+		return nil, nil, err
+	}
+	down, err := o.OpenDown()
+	if err != nil {
+		return nil, nil, err
+	}
+	return up, down, nil
+}
+
+// openUp returns the up script of the migration, reporting errors opening it if it implements Opener
+func openUp(migration Migration) (io.Reader, error) {
+	if o, ok := migration.(Opener); ok {
+		return o.OpenUp()
+	}
+	return migration.Up(), nil
+}
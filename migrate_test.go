@@ -3,17 +3,24 @@ package migrate
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"reflect"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 type MockMigration struct {
-	name string
-	up   *bytes.Buffer
-	down *bytes.Buffer
+	name          string
+	up            *bytes.Buffer
+	down          *bytes.Buffer
+	noTransaction bool
 }
 
 func (m *MockMigration) Name() string {
@@ -21,11 +28,15 @@ func (m *MockMigration) Name() string {
 }
 
 func (m *MockMigration) Up() io.Reader {
-	return m.up
+	return bytes.NewReader(m.up.Bytes())
 }
 
 func (m *MockMigration) Down() io.Reader {
-	return m.down
+	return bytes.NewReader(m.down.Bytes())
+}
+
+func (m *MockMigration) NoTransaction() bool {
+	return m.noTransaction
 }
 
 func (m *MockMigration) Close() {
@@ -34,35 +45,42 @@ func (m *MockMigration) Close() {
 
 type MockProvider struct {
 	nextErr error
-	pos     int
-	names   []string
+	// endErr is returned with a nil migration once names run out
+	endErr error
+	pos    int
+	names  []string
+	noTx   []string
 }
 
 func (m *MockProvider) Next() (Migration, error) {
 	if m.pos >= len(m.names) {
-		return nil, nil
+		return nil, m.endErr
 	}
 	name := m.names[m.pos]
 	migration := &MockMigration{
-		name: name,
-		up:   bytes.NewBufferString("up " + name),
-		down: bytes.NewBufferString("down " + name),
+		name:          name,
+		up:            bytes.NewBufferString("up " + name),
+		down:          bytes.NewBufferString("down " + name),
+		noTransaction: slices.Contains(m.noTx, name),
 	}
 	m.pos++
 	return migration, m.nextErr
 }
 
 type MockAdapter struct {
-	setupErr    error
-	listErr     error
-	beginErr    error
-	upErr       error
-	downErr     error
-	commitErr   error
-	rollbackErr error
-	applied     []string
-	up          []string
-	down        []string
+	setupErr       error
+	listErr        error
+	beginErr       error
+	upErr          error
+	downErr        error
+	commitErr      error
+	rollbackErr    error
+	rollbackSQLErr error
+	failUp         string
+	applied        []string
+	up             []string
+	down           []string
+	calls          []string
 }
 
 func (m *MockAdapter) Setup() error {
@@ -77,6 +95,7 @@ func (m *MockAdapter) List() ([]string, error) {
 }
 
 func (m *MockAdapter) Begin(ctx context.Context) error {
+	m.calls = append(m.calls, "begin")
 	m.up = make([]string, 0)
 	m.down = make([]string, 0)
 	return m.beginErr
@@ -99,16 +118,26 @@ func (m *MockAdapter) Up(name string, up, down io.Reader) error {
 			return fmt.Errorf("down %s not valid", name)
 		}
 	}
+	m.calls = append(m.calls, "up "+name)
 	m.up = append(m.up, name)
+	if m.failUp == name {
+		return fmt.Errorf("fail up %s", name)
+	}
 	return m.upErr
 }
 
 func (m *MockAdapter) Down(name string) error {
+	m.calls = append(m.calls, "down "+name)
 	m.down = append(m.down, name)
 	return m.downErr
 }
 
+func (m *MockAdapter) RollbackSQL(name string) (string, error) {
+	return "down " + name, m.rollbackSQLErr
+}
+
 func (m *MockAdapter) Commit() error {
+	m.calls = append(m.calls, "commit")
 	for _, name := range m.down {
 		rmi := slices.Index(m.applied, name)
 		applied := append(m.applied[0:rmi], m.applied[rmi+1:]...)
@@ -121,6 +150,7 @@ func (m *MockAdapter) Commit() error {
 }
 
 func (m *MockAdapter) Rollback() error {
+	m.calls = append(m.calls, "rollback")
 	return m.rollbackErr
 }
 
@@ -208,7 +238,7 @@ func TestMigrate_Migrate(t *testing.T) {
 		},
 		{
 			name: "migrate with down error",
-			m:    New(WithAdapter(&MockAdapter{applied: []string{"bbb"}, downErr: fmt.Errorf("fail down")}), WithProvider(&MockProvider{names: []string{"aaa"}})),
+			m:    New(WithAdapter(&MockAdapter{applied: []string{"bbb"}, downErr: fmt.Errorf("fail down")}), WithProvider(&MockProvider{names: []string{"aaa"}}), WithMissingPolicy(MissingRollback)),
 			args: args{
 				ctx: context.Background(),
 			},
@@ -248,6 +278,66 @@ func TestMigrate_Migrate(t *testing.T) {
 	}
 }
 
+func TestMigrate_Migrate_MissingPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      MissingPolicy
+		wantErr     bool
+		wantApplied []string
+	}{
+		{
+			name:        "missing with error policy",
+			policy:      MissingError,
+			wantErr:     true,
+			wantApplied: []string{"aaa", "bbb"},
+		},
+		{
+			name:        "missing with skip policy",
+			policy:      MissingSkip,
+			wantApplied: []string{"aaa", "bbb", "ccc"},
+		},
+		{
+			name:        "missing with rollback policy",
+			policy:      MissingRollback,
+			wantApplied: []string{"aaa", "ccc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockAdapter{applied: []string{"aaa", "bbb"}}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "ccc"}}), WithMissingPolicy(tt.policy))
+			err := m.Migrate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var missingErr *MissingMigrationsError
+			if tt.wantErr && (!errors.As(err, &missingErr) || !reflect.DeepEqual(missingErr.Names, []string{"bbb"})) {
+				t.Errorf("Migrate() error = %v, want MissingMigrationsError for [bbb]", err)
+			}
+			if !reflect.DeepEqual(a.applied, tt.wantApplied) {
+				t.Errorf("Migrate() applied = %v, want %v", a.applied, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestMigrate_Migrate_WithProviderError(t *testing.T) {
+	// a provider failing without a migration must not look like one that ran out of migrations, or the migrations it
+	// did not return would be taken down as missing
+	a := &MockAdapter{applied: []string{"aaa", "bbb"}}
+	p := &MockProvider{names: []string{"aaa"}, endErr: errors.New("duplicate migration")}
+	m := New(WithAdapter(a), WithProvider(p), WithMissingPolicy(MissingRollback))
+	if err := m.Migrate(context.Background()); err == nil {
+		t.Errorf("Migrate() error = %v, wantErr %v", err, true)
+	}
+	if want := []string{"aaa", "bbb"}; !reflect.DeepEqual(a.applied, want) {
+		t.Errorf("Migrate() applied = %v, want %v", a.applied, want)
+	}
+	if len(a.calls) != 0 {
+		t.Errorf("Migrate() calls = %v, want none", a.calls)
+	}
+}
+
 func TestNew(t *testing.T) {
 	type args struct {
 		opts []Option
@@ -272,7 +362,8 @@ func TestNew(t *testing.T) {
 				},
 			},
 			want: &Migrate{
-				a: a,
+				a:       AdaptContext(a),
+				adapter: a,
 			},
 		},
 		{
@@ -295,3 +386,526 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+type MockLockAdapter struct {
+	MockAdapter
+	lockErr   error
+	unlockErr error
+	locked    bool
+}
+
+func (m *MockLockAdapter) Lock(ctx context.Context) error {
+	m.calls = append(m.calls, "lock")
+	if m.lockErr != nil {
+		return m.lockErr
+	}
+	m.locked = true
+	return nil
+}
+
+func (m *MockLockAdapter) Unlock() error {
+	m.calls = append(m.calls, "unlock")
+	m.locked = false
+	return m.unlockErr
+}
+
+func (m *MockLockAdapter) Setup() error {
+	m.calls = append(m.calls, "setup")
+	return m.MockAdapter.Setup()
+}
+
+func TestMigrate_Migrate_WithLock(t *testing.T) {
+	tests := []struct {
+		name      string
+		a         *MockLockAdapter
+		wantErr   error
+		wantCalls []string
+	}{
+		{
+			name:      "lock held for the whole run",
+			a:         &MockLockAdapter{},
+			wantCalls: []string{"lock", "setup", "begin", "up aaa", "commit", "unlock"},
+		},
+		{
+			name:      "lock timeout",
+			a:         &MockLockAdapter{lockErr: fmt.Errorf("timed out: %w", context.DeadlineExceeded)},
+			wantErr:   ErrLocked,
+			wantCalls: []string{"lock"},
+		},
+		{
+			name:      "unlock error is not fatal",
+			a:         &MockLockAdapter{unlockErr: fmt.Errorf("fail unlock")},
+			wantCalls: []string{"lock", "setup", "begin", "up aaa", "commit", "unlock"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(WithAdapter(tt.a), WithProvider(&MockProvider{names: []string{"aaa"}}), WithLockTimeout(time.Second))
+			err := m.Migrate(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(tt.a.calls, tt.wantCalls) {
+				t.Errorf("Migrate() calls = %v, want %v", tt.a.calls, tt.wantCalls)
+			}
+			if tt.a.locked {
+				t.Errorf("Migrate() left the lock held")
+			}
+		})
+	}
+}
+
+func TestMigrate_Migrate_TxMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          TxMode
+		failUp        string
+		wantCalls     []string
+		wantCommitted []string
+		wantErr       bool
+	}{
+		{
+			name:      "single transaction",
+			mode:      TxSingle,
+			wantCalls: []string{"begin", "down ccc", "up aaa", "up bbb", "commit"},
+		},
+		{
+			name:      "single transaction with failure",
+			mode:      TxSingle,
+			failUp:    "bbb",
+			wantCalls: []string{"begin", "down ccc", "up aaa", "up bbb", "rollback"},
+			wantErr:   true,
+		},
+		{
+			name:      "transaction per migration",
+			mode:      TxPerMigration,
+			wantCalls: []string{"begin", "down ccc", "commit", "begin", "up aaa", "commit", "begin", "up bbb", "commit"},
+		},
+		{
+			name:          "transaction per migration with failure",
+			mode:          TxPerMigration,
+			failUp:        "bbb",
+			wantCalls:     []string{"begin", "down ccc", "commit", "begin", "up aaa", "commit", "begin", "up bbb", "rollback"},
+			wantCommitted: []string{"ccc", "aaa"},
+			wantErr:       true,
+		},
+		{
+			name:      "no transaction",
+			mode:      TxNone,
+			wantCalls: []string{"down ccc", "up aaa", "up bbb"},
+		},
+		{
+			name:          "no transaction with failure",
+			mode:          TxNone,
+			failUp:        "bbb",
+			wantCalls:     []string{"down ccc", "up aaa", "up bbb"},
+			wantCommitted: []string{"ccc", "aaa"},
+			wantErr:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockAdapter{applied: []string{"ccc"}, failUp: tt.failUp}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb"}}), WithMissingPolicy(MissingRollback), WithTxMode(tt.mode))
+			err := m.Migrate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(a.calls, tt.wantCalls) {
+				t.Errorf("Migrate() calls = %v, want %v", a.calls, tt.wantCalls)
+			}
+			var incompleteErr *IncompleteError
+			if errors.As(err, &incompleteErr) {
+				if !reflect.DeepEqual(incompleteErr.Committed, tt.wantCommitted) {
+					t.Errorf("Migrate() committed = %v, want %v", incompleteErr.Committed, tt.wantCommitted)
+				}
+			} else if tt.wantCommitted != nil {
+				t.Errorf("Migrate() error = %v, want IncompleteError", err)
+			}
+		})
+	}
+}
+
+func TestMigrate_Migrate_NoTransaction(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          TxMode
+		failUp        string
+		wantCalls     []string
+		wantCommitted []string
+		wantErr       bool
+	}{
+		{
+			name:      "single transaction",
+			mode:      TxSingle,
+			wantCalls: []string{"begin", "up aaa", "commit", "up bbb", "begin", "up ccc", "up ddd", "commit"},
+		},
+		{
+			name:          "single transaction with failure after non transactional",
+			mode:          TxSingle,
+			failUp:        "ddd",
+			wantCalls:     []string{"begin", "up aaa", "commit", "up bbb", "begin", "up ccc", "up ddd", "rollback"},
+			wantCommitted: []string{"aaa", "bbb"},
+			wantErr:       true,
+		},
+		{
+			name:      "transaction per migration",
+			mode:      TxPerMigration,
+			wantCalls: []string{"begin", "up aaa", "commit", "up bbb", "begin", "up ccc", "commit", "begin", "up ddd", "commit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockAdapter{failUp: tt.failUp}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc", "ddd"}, noTx: []string{"bbb"}}), WithTxMode(tt.mode))
+			err := m.Migrate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(a.calls, tt.wantCalls) {
+				t.Errorf("Migrate() calls = %v, want %v", a.calls, tt.wantCalls)
+			}
+			var incompleteErr *IncompleteError
+			if errors.As(err, &incompleteErr) && !reflect.DeepEqual(incompleteErr.Committed, tt.wantCommitted) {
+				t.Errorf("Migrate() committed = %v, want %v", incompleteErr.Committed, tt.wantCommitted)
+			}
+		})
+	}
+}
+
+type MockFuncMigration struct {
+	MockMigration
+	up   MigrationFunc
+	down MigrationFunc
+}
+
+func (m *MockFuncMigration) UpFunc() MigrationFunc {
+	return m.up
+}
+
+func (m *MockFuncMigration) DownFunc() MigrationFunc {
+	return m.down
+}
+
+type MockFuncProvider struct {
+	pos        int
+	migrations []Migration
+}
+
+func (m *MockFuncProvider) Next() (Migration, error) {
+	if m.pos >= len(m.migrations) {
+		return nil, nil
+	}
+	m.pos++
+	return m.migrations[m.pos-1], nil
+}
+
+type MockFuncAdapter struct {
+	MockAdapter
+}
+
+func (m *MockFuncAdapter) UpFunc(ctx context.Context, name string, up func(context.Context, *sql.Tx) error) error {
+	m.calls = append(m.calls, "up func "+name)
+	if err := up(ctx, nil); err != nil {
+		return err
+	}
+	m.up = append(m.up, name)
+	return nil
+}
+
+func (m *MockFuncAdapter) DownFunc(ctx context.Context, name string, down func(context.Context, *sql.Tx) error) error {
+	m.calls = append(m.calls, "down func "+name)
+	if err := down(ctx, nil); err != nil {
+		return err
+	}
+	m.down = append(m.down, name)
+	return nil
+}
+
+func TestMigrate_Migrate_FuncMigration(t *testing.T) {
+	var ran []string
+	record := func(name string, err error) MigrationFunc {
+		return func(ctx context.Context, tx *sql.Tx) error {
+			ran = append(ran, name)
+			return err
+		}
+	}
+	newProvider := func(upErr error) *MockFuncProvider {
+		return &MockFuncProvider{migrations: []Migration{
+			&MockMigration{name: "aaa", up: bytes.NewBufferString("up aaa"), down: bytes.NewBufferString("down aaa")},
+			&MockFuncMigration{MockMigration: MockMigration{name: "bbb"}, up: record("up bbb", upErr), down: record("down bbb", nil)},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		a         Adapter
+		p         Provider
+		wantRan   []string
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name:      "apply go migration",
+			a:         &MockFuncAdapter{},
+			p:         newProvider(nil),
+			wantRan:   []string{"up bbb"},
+			wantCalls: []string{"begin", "up aaa", "up func bbb", "commit"},
+		},
+		{
+			name:      "apply go migration with error",
+			a:         &MockFuncAdapter{},
+			p:         newProvider(fmt.Errorf("fail up func")),
+			wantRan:   []string{"up bbb"},
+			wantCalls: []string{"begin", "up aaa", "up func bbb", "rollback"},
+			wantErr:   true,
+		},
+		{
+			name:    "apply go migration without func adapter",
+			a:       &MockAdapter{},
+			p:       newProvider(nil),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran = nil
+			m := New(WithAdapter(tt.a), WithProvider(tt.p))
+			if err := m.Migrate(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(ran, tt.wantRan) {
+				t.Errorf("Migrate() ran = %v, want %v", ran, tt.wantRan)
+			}
+			if fa, ok := tt.a.(*MockFuncAdapter); ok && !reflect.DeepEqual(fa.calls, tt.wantCalls) {
+				t.Errorf("Migrate() calls = %v, want %v", fa.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMigrate_Rollback_FuncMigration(t *testing.T) {
+	var ran []string
+	p := &MockFuncProvider{migrations: []Migration{
+		&MockFuncMigration{
+			MockMigration: MockMigration{name: "aaa"},
+			up:            func(ctx context.Context, tx *sql.Tx) error { return nil },
+			down: func(ctx context.Context, tx *sql.Tx) error {
+				ran = append(ran, "down aaa")
+				return nil
+			},
+		},
+	}}
+	a := &MockFuncAdapter{MockAdapter: MockAdapter{applied: []string{"aaa"}}}
+	m := New(WithAdapter(a), WithProvider(p))
+	if err := m.Rollback(context.Background(), 1); err != nil {
+		t.Errorf("Rollback() unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(ran, []string{"down aaa"}) {
+		t.Errorf("Rollback() ran = %v, want %v", ran, []string{"down aaa"})
+	}
+	if len(a.applied) != 0 {
+		t.Errorf("Rollback() applied = %v, want none", a.applied)
+	}
+}
+
+type MockOpenerMigration struct {
+	MockMigration
+	openErr error
+}
+
+func (m *MockOpenerMigration) OpenUp() (io.Reader, error) {
+	if m.openErr != nil {
+		return nil, m.openErr
+	}
+	return m.Up(), nil
+}
+
+func (m *MockOpenerMigration) OpenDown() (io.Reader, error) {
+	return m.Down(), nil
+}
+
+func TestMigrate_Migrate_WithOpenError(t *testing.T) {
+	p := &MockFuncProvider{migrations: []Migration{
+		&MockMigration{name: "aaa", up: bytes.NewBufferString("up aaa"), down: bytes.NewBufferString("down aaa")},
+		&MockOpenerMigration{
+			MockMigration: MockMigration{name: "bbb", up: bytes.NewBufferString("up bbb"), down: bytes.NewBufferString("down bbb")},
+			openErr:       fmt.Errorf("cannot open migration file 'bbb.up.sql'"),
+		},
+	}}
+	a := &MockAdapter{}
+	m := New(WithAdapter(a), WithProvider(p))
+
+	err := m.Migrate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "bbb.up.sql") {
+		t.Errorf("Migrate() error = %v, want error naming bbb.up.sql", err)
+	}
+	wantCalls := []string{"begin", "up aaa", "rollback"}
+	if !reflect.DeepEqual(a.calls, wantCalls) {
+		t.Errorf("Migrate() calls = %v, want %v", a.calls, wantCalls)
+	}
+}
+
+type MockSlowAdapter struct {
+	MockContextAdapter
+	slow             string
+	upErr            error
+	statementTimeout time.Duration
+}
+
+func (m *MockSlowAdapter) SetStatementTimeout(d time.Duration) {
+	m.statementTimeout = d
+}
+
+func (m *MockSlowAdapter) UpContext(ctx context.Context, name string, up, down io.Reader) error {
+	if name == m.slow {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if m.upErr != nil {
+		return m.upErr
+	}
+	return m.MockContextAdapter.UpContext(ctx, name, up, down)
+}
+
+func TestMigrate_Migrate_WithMigrationTimeout(t *testing.T) {
+	a := &MockSlowAdapter{slow: "bbb"}
+	m := New(
+		WithContextAdapter(a),
+		WithProvider(&MockProvider{names: []string{"aaa", "bbb"}}),
+		WithMigrationTimeout(10*time.Millisecond),
+		WithStatementTimeout(time.Second),
+	)
+
+	err := m.Migrate(context.Background())
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("Migrate() error = %v, want *TimeoutError", err)
+	}
+	if te.Migration != "bbb" || te.Timeout != 10*time.Millisecond || te.Statement != "" {
+		t.Errorf("Migrate() error = %+v, want migration 'bbb' with timeout 10ms", te)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Migrate() error = %v, want it to wrap context.DeadlineExceeded", err)
+	}
+	if a.statementTimeout != time.Second {
+		t.Errorf("SetStatementTimeout() got %v, want %v", a.statementTimeout, time.Second)
+	}
+	wantCalls := []string{"begin", "up aaa", "rollback"}
+	if !reflect.DeepEqual(a.calls, wantCalls) {
+		t.Errorf("Migrate() calls = %v, want %v", a.calls, wantCalls)
+	}
+}
+
+func TestMigrate_Migrate_WithStatementTimeoutError(t *testing.T) {
+	a := &MockSlowAdapter{upErr: &TimeoutError{Statement: "select pg_sleep(10)", Timeout: time.Second, Err: context.DeadlineExceeded}}
+	m := New(WithContextAdapter(a), WithProvider(&MockProvider{names: []string{"aaa"}}))
+
+	err := m.Migrate(context.Background())
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("Migrate() error = %v, want *TimeoutError", err)
+	}
+	if te.Migration != "aaa" || te.Statement != "select pg_sleep(10)" || te.Timeout != time.Second {
+		t.Errorf("Migrate() error = %+v, want migration 'aaa' and the reported statement", te)
+	}
+}
+
+func TestMigrate_Migrate_WithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	a := &MockAdapter{applied: []string{"aaa", "ccc"}}
+	m := New(
+		WithAdapter(a),
+		WithProvider(&MockProvider{names: []string{"aaa", "bbb"}}),
+		WithMissingPolicy(MissingSkip),
+		WithLogger(logger),
+	)
+	if err := m.Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate() unexpected error %v", err)
+	}
+
+	var got []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record struct {
+			Level     string
+			Msg       string
+			Migration string
+			Direction string
+		}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("cannot decode log record: %v", err)
+		}
+		got = append(got, strings.TrimSpace(fmt.Sprintf("%s %s %s %s", record.Level, record.Msg, record.Direction, record.Migration)))
+	}
+	want := []string{
+		"WARN skipping applied migrations missing from provider",
+		"INFO starting migration run",
+		"INFO migration done up bbb",
+		"INFO finished migration run",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Migrate() logged %q, want %q", got, want)
+	}
+}
+
+func TestMigrate_Verify(t *testing.T) {
+	tests := []struct {
+		name      string
+		applied   []string
+		checksums map[string]string
+		opts      []Option
+		wantErr   any
+	}{
+		{
+			name:      "everything matches",
+			applied:   []string{"aaa"},
+			checksums: map[string]string{"aaa": mockSum("aaa")},
+		},
+		{
+			name:      "missing migration",
+			applied:   []string{"aaa", "abc"},
+			checksums: map[string]string{"aaa": mockSum("aaa")},
+			wantErr:   &MissingMigrationsError{},
+		},
+		{
+			name:      "missing migration skipped",
+			applied:   []string{"aaa", "abc"},
+			checksums: map[string]string{"aaa": mockSum("aaa")},
+			opts:      []Option{WithMissingPolicy(MissingSkip)},
+		},
+		{
+			name:      "changed migration",
+			applied:   []string{"aaa"},
+			checksums: map[string]string{"aaa": mockSum("changed")},
+			wantErr:   &ChecksumMismatchError{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockChecksumAdapter{MockAdapter: MockAdapter{applied: tt.applied}, checksums: tt.checksums}
+			opts := append([]Option{WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb"}})}, tt.opts...)
+			m := New(opts...)
+
+			err := m.Verify(context.Background())
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("Verify() unexpected error %v", err)
+				}
+			case *MissingMigrationsError:
+				if !errors.As(err, &want) {
+					t.Errorf("Verify() error = %v, want %T", err, want)
+				}
+			case *ChecksumMismatchError:
+				if !errors.As(err, &want) {
+					t.Errorf("Verify() error = %v, want %T", err, want)
+				}
+			}
+			if len(a.calls) != 0 {
+				t.Errorf("Verify() calls = %v, want none", a.calls)
+			}
+		})
+	}
+}
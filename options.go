@@ -1,5 +1,10 @@
 package migrate
 
+import (
+	"log/slog"
+	"time"
+)
+
 func WithProvider(p Provider) Option {
 	return func(m *Migrate) {
 		m.p = p
@@ -7,7 +12,113 @@ func WithProvider(p Provider) Option {
 }
 
 func WithAdapter(a Adapter) Option {
+	return func(m *Migrate) {
+		m.a = AdaptContext(a)
+		m.adapter = a
+	}
+}
+
+// WithContextAdapter sets an adapter that only implements ContextAdapter
+func WithContextAdapter(a ContextAdapter) Option {
 	return func(m *Migrate) {
 		m.a = a
+		m.adapter = a
+	}
+}
+
+// WithLogger logs the run and every migration taken down or applied as structured records with the migration name,
+// direction and duration. Nothing is logged if not set.
+func WithLogger(l *slog.Logger) Option {
+	return func(m *Migrate) {
+		m.logger = l
+	}
+}
+
+// WithMissingPolicy sets what happens to applied migrations that are no longer known to the provider. Defaults to
+// MissingError.
+func WithMissingPolicy(policy MissingPolicy) Option {
+	return func(m *Migrate) {
+		m.missing = policy
+	}
+}
+
+// WithChecksumPolicy sets what happens when the up script of an applied migration changed since it was applied.
+// Defaults to ChecksumError. Only applies to adapters implementing Checksummer.
+func WithChecksumPolicy(policy ChecksumPolicy) Option {
+	return func(m *Migrate) {
+		m.checksum = policy
+	}
+}
+
+// WithLockTimeout limits how long to wait for the migration lock before giving up with ErrLocked. Waits until the
+// context is done if not set. Only applies to adapters implementing Locker.
+func WithLockTimeout(d time.Duration) Option {
+	return func(m *Migrate) {
+		m.lockTimeout = d
+	}
+}
+
+// WithTxMode sets how migrations are grouped into transactions. Defaults to TxSingle.
+func WithTxMode(mode TxMode) Option {
+	return func(m *Migrate) {
+		m.txMode = mode
+	}
+}
+
+// WithMigrationTimeout limits how long each migration may run before it is cancelled with a *TimeoutError
+func WithMigrationTimeout(d time.Duration) Option {
+	return func(m *Migrate) {
+		m.migrationTimeout = d
+	}
+}
+
+// WithStatementTimeout limits how long each statement may run before it is cancelled with a *TimeoutError. Only
+// applies to adapters implementing StatementTimeouter.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(m *Migrate) {
+		m.statementTimeout = d
+	}
+}
+
+// WithRetryPolicy runs transactions that failed with a retryable error again according to the policy. Nothing is
+// retried if not set.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(m *Migrate) {
+		m.retry = policy
+	}
+}
+
+// WithBeforeRun adds a hook called before any migration is taken down or applied
+func WithBeforeRun(h Hook) Option {
+	return func(m *Migrate) {
+		m.hooks.beforeRun = append(m.hooks.beforeRun, h)
+	}
+}
+
+// WithAfterRun adds a hook called once every migration was successfully taken down or applied
+func WithAfterRun(h Hook) Option {
+	return func(m *Migrate) {
+		m.hooks.afterRun = append(m.hooks.afterRun, h)
+	}
+}
+
+// WithBeforeMigration adds a hook called before each migration is taken down or applied
+func WithBeforeMigration(h Hook) Option {
+	return func(m *Migrate) {
+		m.hooks.beforeMigration = append(m.hooks.beforeMigration, h)
+	}
+}
+
+// WithAfterMigration adds a hook called after each migration was successfully taken down or applied
+func WithAfterMigration(h Hook) Option {
+	return func(m *Migrate) {
+		m.hooks.afterMigration = append(m.hooks.afterMigration, h)
+	}
+}
+
+// WithOnFailure adds a hook called when a run fails, with the error it failed with
+func WithOnFailure(h Hook) Option {
+	return func(m *Migrate) {
+		m.hooks.onFailure = append(m.hooks.onFailure, h)
 	}
 }
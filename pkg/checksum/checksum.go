@@ -0,0 +1,45 @@
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Reader computes the checksum of everything read through it
+type Reader struct {
+	source io.Reader
+	hash   hash.Hash
+}
+
+func NewReader(source io.Reader) *Reader {
+	h := sha256.New()
+	return &Reader{
+		source: io.TeeReader(source, h),
+		hash:   h,
+	}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.source.Read(p)
+}
+
+// Sum returns the hex encoded checksum of what has been read so far
+func (r *Reader) Sum() string {
+	return hex.EncodeToString(r.hash.Sum(nil))
+}
+
+// Drain reads what is left of the source and returns the checksum of the full content
+func (r *Reader) Drain() (string, error) {
+	if _, err := io.Copy(io.Discard, r.source); err != nil {
+		return "", fmt.Errorf("failed to read source: %w", err)
+	}
+	return r.Sum(), nil
+}
+
+// Sum returns the hex encoded checksum of the full content of source
+func Sum(source io.Reader) (string, error) {
+	return NewReader(source).Drain()
+}
@@ -0,0 +1,69 @@
+package checksum
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type FailReader struct{}
+
+func (r FailReader) Read(_ []byte) (n int, err error) {
+	return 0, errors.New("read error")
+}
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  io.Reader
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "empty",
+			source: bytes.NewBufferString(""),
+			want:   "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		{
+			name:   "content",
+			source: bytes.NewBufferString("CREATE TABLE test (id INT);"),
+			want:   "789141b85942dc7961d826bf7df365f2ba88215dcc8111dfda4dcd6208899121",
+		},
+		{
+			name:    "read error",
+			source:  FailReader{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Sum(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Sum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Sum() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_Drain(t *testing.T) {
+	r := NewReader(bytes.NewBufferString("CREATE TABLE test (id INT);"))
+	buf := make([]byte, 6)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("Read() unexpected error %v", err)
+	}
+	if string(buf) != "CREATE" {
+		t.Errorf("Read() = %v, want %v", string(buf), "CREATE")
+	}
+	got, err := r.Drain()
+	if err != nil {
+		t.Errorf("Drain() unexpected error %v", err)
+	}
+	want, _ := Sum(bytes.NewBufferString("CREATE TABLE test (id INT);"))
+	if got != want {
+		t.Errorf("Drain() = %v, want %v", got, want)
+	}
+}
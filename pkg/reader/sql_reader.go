@@ -7,6 +7,7 @@ import (
 	"io"
 	"regexp"
 	"strings"
+	"unicode"
 )
 
 // FailNext will return the specified error  when calling Next() on you SQLReader.
@@ -15,6 +16,9 @@ var FailNext error
 
 type SQLReader struct {
 	source *bufio.Reader
+	// newlines is the number of newlines consumed from source
+	newlines int
+	line     int
 }
 
 func NewSQLReader(source io.Reader) *SQLReader {
@@ -23,6 +27,59 @@ func NewSQLReader(source io.Reader) *SQLReader {
 	}
 }
 
+// SyntaxError is returned by Next when the source ends inside a quoted string, comment or COPY data block
+type SyntaxError struct {
+	// Line the unterminated string, comment or data block starts on
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s starting on line %d", e.Msg, e.Line)
+}
+
+// Line returns the line the statement last returned by Next starts on, counting from 1
+func (r *SQLReader) Line() int {
+	return r.line
+}
+
+// nextLine returns the line of the next byte to be consumed from the source, counting from 1
+func (r *SQLReader) nextLine() int {
+	return r.newlines + 1
+}
+
+// syntaxError returns a *SyntaxError for the construct starting on line
+func (r *SQLReader) syntaxError(line int, msg string) error {
+	return &SyntaxError{Line: line, Msg: msg}
+}
+
+// readByte reads a byte from the source, counting newlines
+func (r *SQLReader) readByte() (byte, error) {
+	b, err := r.source.ReadByte()
+	if err == nil && b == '\n' {
+		r.newlines++
+	}
+	return b, err
+}
+
+// readRune reads a rune from the source, counting newlines
+func (r *SQLReader) readRune() (rune, error) {
+	v, _, err := r.source.ReadRune()
+	if err == nil && v == '\n' {
+		r.newlines++
+	}
+	return v, err
+}
+
+// readLine reads up to and including the next newline from the source, counting it
+func (r *SQLReader) readLine() (string, error) {
+	line, err := r.source.ReadString('\n')
+	if strings.HasSuffix(line, "\n") {
+		r.newlines++
+	}
+	return line, err
+}
+
 // copyFromStdinRe matches a `COPY ... FROM stdin` statement so the inline data
 // block following it can be skipped.
 var copyFromStdinRe = regexp.MustCompile(`(?is)^\s*COPY\b.*\bFROM\s+STDIN\b`)
@@ -42,14 +99,21 @@ func (r *SQLReader) Next() (query string, err error) {
 	// the tag between the dollar signs (empty string for `$$`).
 	var inDollar bool
 	var dollarTag string
+	// start is the line of the first character of the statement, 0 until it is found, and quoteStart the line of the
+	// open quote or dollar tag
+	start, quoteStart := 0, 0
 	for {
 		if !quote && !inDollar {
 			// Dollar-quote delimiters.
 			if tag, n, ok := r.peekDollarTag(); ok {
 				inDollar = true
 				dollarTag = tag
+				quoteStart = r.nextLine()
+				if start == 0 {
+					start = quoteStart
+				}
 				for range n {
-					b, rerr := r.source.ReadByte()
+					b, rerr := r.readByte()
 					if rerr != nil {
 						return "", fmt.Errorf("failed to read source: %w", rerr)
 					}
@@ -60,7 +124,7 @@ func (r *SQLReader) Next() (query string, err error) {
 			// `--` line comment.
 			if b, _ := r.source.Peek(2); len(b) == 2 && b[0] == '-' && b[1] == '-' {
 				for {
-					b, rerr := r.source.ReadByte()
+					b, rerr := r.readByte()
 					if rerr != nil {
 						if rerr == io.EOF {
 							break
@@ -75,6 +139,7 @@ func (r *SQLReader) Next() (query string, err error) {
 			}
 			// `/* ... */` block comment (nestable).
 			if b, _ := r.source.Peek(2); len(b) == 2 && b[0] == '/' && b[1] == '*' {
+				commentStart := r.nextLine()
 				if _, rerr := r.source.Discard(2); rerr != nil {
 					return "", fmt.Errorf("failed to read source: %w", rerr)
 				}
@@ -92,9 +157,9 @@ func (r *SQLReader) Next() (query string, err error) {
 						continue
 					}
 					if len(b) < 2 {
-						return "", fmt.Errorf("unterminated block comment")
+						return "", r.syntaxError(commentStart, "unterminated block comment")
 					}
-					if _, rerr := r.source.ReadByte(); rerr != nil {
+					if _, rerr := r.readByte(); rerr != nil {
 						return "", fmt.Errorf("failed to read source: %w", rerr)
 					}
 				}
@@ -108,7 +173,7 @@ func (r *SQLReader) Next() (query string, err error) {
 				inDollar = false
 				dollarTag = ""
 				for range n {
-					b, rerr := r.source.ReadByte()
+					b, rerr := r.readByte()
 					if rerr != nil {
 						return "", fmt.Errorf("failed to read source: %w", rerr)
 					}
@@ -118,12 +183,24 @@ func (r *SQLReader) Next() (query string, err error) {
 			}
 		}
 
-		v, _, err := r.source.ReadRune()
+		pos := r.nextLine()
+		v, err := r.readRune()
 		if err != nil {
-			if err == io.EOF {
-				break
+			if err != io.EOF {
+				return "", fmt.Errorf("failed to read source: %w", err)
+			}
+			switch {
+			case inDollar:
+				return "", r.syntaxError(quoteStart, "unterminated dollar-quoted string")
+			case quote && quoteChar == '"':
+				return "", r.syntaxError(quoteStart, "unterminated quoted identifier")
+			case quote:
+				return "", r.syntaxError(quoteStart, "unterminated quoted string")
 			}
-			return "", fmt.Errorf("failed to read source: %w", err)
+			break
+		}
+		if start == 0 && !unicode.IsSpace(v) && v != ';' {
+			start = pos
 		}
 
 		if inDollar {
@@ -146,7 +223,7 @@ func (r *SQLReader) Next() (query string, err error) {
 				// Doubled quote (`''` / `""`) is an escape — stay inside the literal.
 				if next, _ := r.source.Peek(1); len(next) == 1 && rune(next[0]) == quoteChar {
 					buf.WriteRune(v)
-					r.source.ReadByte()
+					r.readByte()
 					buf.WriteRune(quoteChar)
 					continue
 				}
@@ -164,6 +241,7 @@ func (r *SQLReader) Next() (query string, err error) {
 		if v == '\'' || v == '"' {
 			quote = true
 			quoteChar = v
+			quoteStart = pos
 			if v == '\'' && bufEndsWithEPrefix(&buf) {
 				eString = true
 			}
@@ -177,6 +255,7 @@ func (r *SQLReader) Next() (query string, err error) {
 	}
 
 	stmt := strings.TrimSpace(buf.String())
+	r.line = start
 
 	// `COPY ... FROM stdin;` is followed by an inline data block terminated by
 	// a line containing only `\.`. That data is not SQL and would otherwise be
@@ -193,14 +272,15 @@ func (r *SQLReader) Next() (query string, err error) {
 // skipCopyData consumes a Postgres COPY data block up to and including the
 // terminating `\.` line.
 func (r *SQLReader) skipCopyData() error {
+	dataStart := r.nextLine()
 	for {
-		line, err := r.source.ReadString('\n')
+		line, err := r.readLine()
 		trimmed := strings.TrimRight(line, "\r\n")
 		if trimmed == `\.` {
 			return nil
 		}
 		if err == io.EOF {
-			return fmt.Errorf("unterminated COPY data block")
+			return r.syntaxError(dataStart, "unterminated COPY data block")
 		}
 		if err != nil {
 			return fmt.Errorf("failed to read copy data: %w", err)
@@ -277,3 +357,19 @@ func validDollarTag(s string) bool {
 	}
 	return true
 }
+
+// TransactionControl returns true for a statement beginning or ending a transaction: BEGIN, START TRANSACTION, COMMIT
+// and END. The adapters skip these statements, as they begin and commit transactions themselves.
+func TransactionControl(q string) bool {
+	fields := strings.Fields(strings.ToUpper(q))
+	if len(fields) == 0 {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(fields[0], "BEGIN"), strings.HasPrefix(fields[0], "COMMIT"), fields[0] == "END":
+		return true
+	case fields[0] == "START":
+		return len(fields) > 1 && fields[1] == "TRANSACTION"
+	}
+	return false
+}
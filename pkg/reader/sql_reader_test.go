@@ -222,3 +222,104 @@ func TestSQLReader_Next_WithReadFail(t *testing.T) {
 	validateNext(t, r, "", true)
 	validateNext(t, r, "", true)
 }
+
+func TestSQLReader_Line(t *testing.T) {
+	src := bytes.NewBufferString("-- header\nSELECT 1;\n\n/* block\ncomment */ SELECT\n2; SELECT 'a\nb';\n  SELECT 4")
+	r := NewSQLReader(src)
+
+	for _, want := range []struct {
+		query string
+		line  int
+	}{
+		{query: "SELECT 1", line: 2},
+		{query: "SELECT\n2", line: 5},
+		{query: "SELECT 'a\nb'", line: 6},
+		{query: "SELECT 4", line: 8},
+		{query: "", line: 0},
+	} {
+		validateNext(t, r, want.query, false)
+		if got := r.Line(); got != want.line {
+			t.Errorf("Line() after '%v' = %v, want %v", want.query, got, want.line)
+		}
+	}
+}
+
+func TestSQLReader_Next_Unterminated(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantLine int
+		wantMsg  string
+	}{
+		{
+			name:     "quoted string",
+			src:      "SELECT 1;\nSELECT 'never\ncloses;",
+			wantLine: 2,
+			wantMsg:  "unterminated quoted string",
+		},
+		{
+			name:     "quoted identifier",
+			src:      "SELECT 1;\n\nSELECT \"never;",
+			wantLine: 3,
+			wantMsg:  "unterminated quoted identifier",
+		},
+		{
+			name:     "dollar-quoted string",
+			src:      "SELECT 1;\nDO $body$\nBEGIN; END;",
+			wantLine: 2,
+			wantMsg:  "unterminated dollar-quoted string",
+		},
+		{
+			name:     "block comment",
+			src:      "SELECT 1;\nSELECT 2 /* never\ncloses",
+			wantLine: 2,
+			wantMsg:  "unterminated block comment",
+		},
+		{
+			name:     "copy data",
+			src:      "SELECT 1;\nCOPY t FROM stdin;\n1\tfoo\n",
+			wantLine: 2,
+			wantMsg:  "unterminated COPY data block",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewSQLReader(bytes.NewBufferString(tt.src))
+			validateNext(t, r, "SELECT 1", false)
+
+			_, err := r.Next()
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("Next() error = %v, want *SyntaxError", err)
+			}
+			if se.Line != tt.wantLine || se.Msg != tt.wantMsg {
+				t.Errorf("Next() error = %+v, want line %v and message '%v'", se, tt.wantLine, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestTransactionControl(t *testing.T) {
+	tests := []struct {
+		q    string
+		want bool
+	}{
+		{q: "BEGIN", want: true},
+		{q: "begin transaction", want: true},
+		{q: "START TRANSACTION", want: true},
+		{q: "start transaction isolation level serializable", want: true},
+		{q: "COMMIT", want: true},
+		{q: "END", want: true},
+		{q: "START REPLICA", want: false},
+		{q: "ENDPOINT", want: false},
+		{q: "CREATE TABLE a (id INT)", want: false},
+		{q: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			if got := TransactionControl(tt.q); got != tt.want {
+				t.Errorf("TransactionControl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package statements
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -12,9 +13,13 @@ func (stmts Statements) Get(q string) *sql.Stmt {
 }
 
 func Prepare(db *sql.DB, queries ...string) (Statements, error) {
+	return PrepareContext(context.Background(), db, queries...)
+}
+
+func PrepareContext(ctx context.Context, db *sql.DB, queries ...string) (Statements, error) {
 	stmts := make(Statements)
 	for _, q := range queries {
-		stmt, err := db.Prepare(q)
+		stmt, err := db.PrepareContext(ctx, q)
 		if err != nil {
 			return nil, fmt.Errorf("unable to prepare query '%s' with error: %w", q, err)
 		}
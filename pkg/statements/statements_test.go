@@ -1,6 +1,7 @@
 package statements
 
 import (
+	"context"
 	"errors"
 	"io"
 	"testing"
@@ -83,3 +84,23 @@ func TestStatements_Get(t *testing.T) {
 		t.Errorf("statements.Get(\"three\") should return a non-nil value")
 	}
 }
+
+func TestPrepareContext_WithCancelledContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer MustClose(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = PrepareContext(ctx, db, "one")
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("PrepareContext() error = %v, want %v", err, context.Canceled)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Plan describes what Migrate would do without applying anything
+type Plan struct {
+	// Down lists the migrations that would be taken down, in the order they would be taken down
+	Down []PlanStep
+	// Up lists the migrations that would be applied, in the order they would be applied
+	Up []PlanStep
+}
+
+// PlanStep is a single migration in a Plan
+type PlanStep struct {
+	// Name of the migration
+	Name string
+	// SQL that would be executed. For steps in Plan.Down this is only available if the Adapter implements
+	// RollbackReader or ContextRollbackReader
+	SQL string
+}
+
+// Empty returns true if there is nothing to do
+func (p *Plan) Empty() bool {
+	return len(p.Down) == 0 && len(p.Up) == 0
+}
+
+// Plan works out which migrations Migrate would take down and apply without starting a transaction or changing the
+// applied migrations. If the adapter is an Inspector, its store is not created either.
+func (m *Migrate) Plan(ctx context.Context) (*Plan, error) {
+	s, err := m.inspect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.resolveMissing(s); err != nil {
+		return nil, err
+	}
+	if err := m.verifyChecksums(ctx, s); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+
+	rr, canReadRollback := m.rollbackReader()
+	for _, name := range s.down {
+		step := PlanStep{Name: name}
+		if canReadRollback {
+			step.SQL, err = rr.RollbackSQLContext(ctx, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get rollback for migration '%v': %w", name, err)
+			}
+		}
+		plan.Down = append(plan.Down, step)
+	}
+
+	for _, name := range s.up {
+		data, err := readUp(s.migrations[name])
+		if err != nil {
+			return nil, err
+		}
+		plan.Up = append(plan.Up, PlanStep{Name: name, SQL: string(data)})
+	}
+
+	return plan, nil
+}
+
+// readUp returns the full up script of the migration
+func readUp(migration Migration) ([]byte, error) {
+	defer migration.Close()
+	up, err := openUp(migration)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migration '%v': %w", migration.Name(), err)
+	}
+	data, err := io.ReadAll(up)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration '%v': %w", migration.Name(), err)
+	}
+	return data, nil
+}
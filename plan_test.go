@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMigrate_Plan(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       *Migrate
+		want    *Plan
+		wantErr bool
+	}{
+		{
+			name:    "plan with nothing",
+			m:       New(),
+			wantErr: true,
+		},
+		{
+			name: "plan with no migrations",
+			m:    New(WithAdapter(&MockAdapter{}), WithProvider(&MockProvider{})),
+			want: &Plan{},
+		},
+		{
+			name: "plan with new migrations",
+			m:    New(WithAdapter(&MockAdapter{applied: []string{"aaa"}}), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc"}})),
+			want: &Plan{
+				Up: []PlanStep{
+					{Name: "bbb", SQL: "up bbb"},
+					{Name: "ccc", SQL: "up ccc"},
+				},
+			},
+		},
+		{
+			name: "plan with removed migrations",
+			m:    New(WithAdapter(&MockAdapter{applied: []string{"aaa", "bbb"}}), WithProvider(&MockProvider{names: []string{"aaa", "ccc"}}), WithMissingPolicy(MissingRollback)),
+			want: &Plan{
+				Down: []PlanStep{
+					{Name: "bbb", SQL: "down bbb"},
+				},
+				Up: []PlanStep{
+					{Name: "ccc", SQL: "up ccc"},
+				},
+			},
+		},
+		{
+			name:    "plan with removed migrations and error policy",
+			m:       New(WithAdapter(&MockAdapter{applied: []string{"aaa", "bbb"}}), WithProvider(&MockProvider{names: []string{"aaa", "ccc"}})),
+			wantErr: true,
+		},
+		{
+			name:    "plan with setup error",
+			m:       New(WithAdapter(&MockAdapter{setupErr: fmt.Errorf("fail setup")}), WithProvider(&MockProvider{names: []string{"aaa"}})),
+			wantErr: true,
+		},
+		{
+			name:    "plan with adapter list error",
+			m:       New(WithAdapter(&MockAdapter{listErr: fmt.Errorf("fail list")}), WithProvider(&MockProvider{names: []string{"aaa"}})),
+			wantErr: true,
+		},
+		{
+			name:    "plan with rollback sql error",
+			m:       New(WithAdapter(&MockAdapter{applied: []string{"bbb"}, rollbackSQLErr: fmt.Errorf("fail rollback sql")}), WithProvider(&MockProvider{names: []string{"aaa"}}), WithMissingPolicy(MissingRollback)),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Plan(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Plan() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plan() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrate_Plan_DoesNotApply(t *testing.T) {
+	a := &MockAdapter{}
+	m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb"}}))
+
+	plan, err := m.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() unexpected error %v", err)
+	}
+	if len(plan.Up) != 2 {
+		t.Errorf("Plan() Up = %v, want 2 steps", plan.Up)
+	}
+	if len(a.up) != 0 {
+		t.Errorf("Plan() applied migrations %v", a.up)
+	}
+}
+
+// MockInspectorAdapter reads the applied migrations without setting up its store
+type MockInspectorAdapter struct {
+	MockAdapter
+	exists     bool
+	inspectErr error
+}
+
+func (m *MockInspectorAdapter) InspectContext(ctx context.Context) (bool, error) {
+	m.calls = append(m.calls, "inspect")
+	return m.exists, m.inspectErr
+}
+
+func (m *MockInspectorAdapter) Setup() error {
+	m.calls = append(m.calls, "setup")
+	return m.MockAdapter.Setup()
+}
+
+func (m *MockInspectorAdapter) List() ([]string, error) {
+	m.calls = append(m.calls, "list")
+	return m.MockAdapter.List()
+}
+
+func TestMigrate_Inspect(t *testing.T) {
+	tests := []struct {
+		name       string
+		exists     bool
+		inspectErr error
+		wantErr    bool
+		wantCalls  []string
+		wantUp     int
+	}{
+		{
+			name:      "store exists",
+			exists:    true,
+			wantCalls: []string{"inspect", "list"},
+			wantUp:    1,
+		},
+		{
+			name:      "store does not exist",
+			wantCalls: []string{"inspect"},
+			wantUp:    2,
+		},
+		{
+			name:       "inspect error",
+			inspectErr: fmt.Errorf("fail inspect"),
+			wantErr:    true,
+			wantCalls:  []string{"inspect"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockInspectorAdapter{MockAdapter: MockAdapter{applied: []string{"aaa"}}, exists: tt.exists, inspectErr: tt.inspectErr}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb"}}))
+
+			plan, err := m.Plan(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Plan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(plan.Up) != tt.wantUp {
+				t.Errorf("Plan() Up = %v, want %d steps", plan.Up, tt.wantUp)
+			}
+			if !reflect.DeepEqual(a.calls, tt.wantCalls) {
+				t.Errorf("Plan() calls = %v, want %v", a.calls, tt.wantCalls)
+			}
+
+			a.calls = nil
+			if _, err := m.Status(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Status() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := m.Verify(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if slices.Contains(a.calls, "setup") {
+				t.Errorf("Status() and Verify() set up the adapter, calls = %v", a.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Numbering decides how Create numbers new migrations
+type Numbering int
+
+const (
+	// NumberTimestamp numbers migrations with the UTC time they were created at, e.g. 20240506070809_add_users
+	NumberTimestamp Numbering = iota
+	// NumberSequence numbers migrations one after the highest number in the directory, e.g. 0004_add_users. The
+	// number is padded to the width of the existing numbers, or to 4 digits for the first migration.
+	NumberSequence
+)
+
+// ErrCollision is returned by Create when the new migration's name or number is already used in the directory
+var ErrCollision = errors.New("migration already exists")
+
+// timestampFormat is the layout of NumberTimestamp numbers
+const timestampFormat = "20060102150405"
+
+// now is replaced in tests
+var now = time.Now
+
+type createOptions struct {
+	numbering Numbering
+}
+
+type CreateOption func(o *createOptions)
+
+// WithNumbering sets how the new migration is numbered. Defaults to NumberTimestamp.
+func WithNumbering(n Numbering) CreateOption {
+	return func(o *createOptions) {
+		o.numbering = n
+	}
+}
+
+// Create creates an empty up and down file for a new migration in the directory at path, creating the directory if
+// needed. The files are named <number>_<slug>.up.sql and <number>_<slug>.down.sql, where slug is the description
+// lowercased with every run of characters other than letters and digits replaced by an underscore. It fails with
+// ErrCollision if a migration with the same name or number already exists. The paths of the created files are
+// returned.
+func Create(path, description string, opts ...CreateOption) (upPath, downPath string, err error) {
+	o := &createOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	slug := slugify(description)
+	if slug == "" {
+		return "", "", fmt.Errorf("cannot create migration, the description '%v' has no letters or digits", description)
+	}
+
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return "", "", fmt.Errorf("cannot create migrations directory '%v': %w", path, err)
+	}
+	existing, err := existingNames(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	var number string
+	switch o.numbering {
+	case NumberSequence:
+		number = nextSequence(existing)
+	default:
+		number = now().UTC().Format(timestampFormat)
+	}
+	name := number + "_" + slug
+
+	for _, other := range existing {
+		if other == name {
+			return "", "", fmt.Errorf("cannot create migration '%v': %w", name, ErrCollision)
+		}
+		if leadingNumber(other) == number {
+			return "", "", fmt.Errorf("cannot create migration '%v', number %v is used by '%v': %w", name, number, other, ErrCollision)
+		}
+	}
+
+	upPath = filepath.Join(path, name+".up.sql")
+	downPath = filepath.Join(path, name+".down.sql")
+	if err := createFile(upPath); err != nil {
+		return "", "", err
+	}
+	if err := createFile(downPath); err != nil {
+		// don't leave half a migration behind
+		_ = os.Remove(upPath)
+		return "", "", err
+	}
+	return upPath, downPath, nil
+}
+
+// existingNames returns the names of the migrations in the directory at path
+func existingNames(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migrations directory '%v': %w", path, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name, _ := migrationName(entry.Name())
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// nextSequence returns the number following the highest sequence number in names, padded to the width of the existing
+// numbers. Timestamp numbers are ignored so a directory can switch from timestamps to a sequence.
+func nextSequence(names []string) string {
+	highest, width := 0, 4
+	for _, name := range names {
+		number := leadingNumber(name)
+		if len(number) == len(timestampFormat) {
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		if n > highest {
+			highest = n
+		}
+		width = max(width, len(number))
+	}
+	return fmt.Sprintf("%0*d", width, highest+1)
+}
+
+// leadingNumber returns the digits name starts with, whatever separates them from the rest of the name
+func leadingNumber(name string) string {
+	i := strings.IndexFunc(name, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if i < 0 {
+		return name
+	}
+	return name[:i]
+}
+
+// createFile creates an empty file at path, failing with ErrCollision if it already exists
+func createFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("cannot create migration file '%v': %w", path, ErrCollision)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot create migration file '%v': %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot create migration file '%v': %w", path, err)
+	}
+	return nil
+}
+
+// slugify lowercases s and replaces every run of characters other than letters and digits with an underscore
+func slugify(s string) string {
+	var b strings.Builder
+	sep := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if sep && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+			sep = false
+			continue
+		}
+		sep = true
+	}
+	return b.String()
+}
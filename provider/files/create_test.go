@@ -0,0 +1,139 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	now = func() time.Time {
+		return time.Date(2024, 5, 6, 7, 8, 9, 0, time.FixedZone("CEST", 2*60*60))
+	}
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name        string
+		existing    []string
+		description string
+		opts        []CreateOption
+		wantErr     error
+		wantName    string
+	}{
+		{
+			name:        "timestamp",
+			existing:    []string{"20240101000000_init.up.sql"},
+			description: "Add users table!",
+			wantName:    "20240506050809_add_users_table",
+		},
+		{
+			name:        "first in sequence",
+			description: "init",
+			opts:        []CreateOption{WithNumbering(NumberSequence)},
+			wantName:    "0001_init",
+		},
+		{
+			name:        "next in sequence",
+			existing:    []string{"0001_init.up.sql", "0001_init.down.sql", "0009_users.sql", "20240101000000_legacy.up.sql"},
+			description: "orders",
+			opts:        []CreateOption{WithNumbering(NumberSequence)},
+			wantName:    "0010_orders",
+		},
+		{
+			name:        "sequence keeps the existing width",
+			existing:    []string{"000041_users.up.sql"},
+			description: "orders",
+			opts:        []CreateOption{WithNumbering(NumberSequence)},
+			wantName:    "000042_orders",
+		},
+		{
+			name:        "sequence with other separators",
+			existing:    []string{"00001.up.sql", "00005.some-description.up.sql", "00003-other.up.sql"},
+			description: "orders",
+			opts:        []CreateOption{WithNumbering(NumberSequence)},
+			wantName:    "00006_orders",
+		},
+		{
+			name:        "timestamp already used",
+			existing:    []string{"20240506050809_other.up.sql"},
+			description: "users",
+			wantErr:     ErrCollision,
+		},
+		{
+			name:        "timestamp already used with another separator",
+			existing:    []string{"20240506050809-other.up.sql"},
+			description: "users",
+			wantErr:     ErrCollision,
+		},
+		{
+			name:        "name already used",
+			existing:    []string{"20240506050809_users.down.sql"},
+			description: "users",
+			wantErr:     ErrCollision,
+		},
+		{
+			name:        "description without letters or digits",
+			description: "---",
+			wantErr:     errors.New("any"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			up, down, err := Create(dir, tt.description, tt.opts...)
+			if tt.wantErr != nil {
+				if err == nil || (errors.Is(tt.wantErr, ErrCollision) && !errors.Is(err, ErrCollision)) {
+					t.Errorf("Create() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() unexpected error %v", err)
+			}
+			wantUp := filepath.Join(dir, tt.wantName+".up.sql")
+			wantDown := filepath.Join(dir, tt.wantName+".down.sql")
+			if up != wantUp || down != wantDown {
+				t.Errorf("Create() = %v, %v, want %v, %v", up, down, wantUp, wantDown)
+			}
+			for _, path := range []string{up, down} {
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("Create() did not create %v: %v", path, err)
+				}
+			}
+
+			p, err := Load(dir)
+			if err != nil {
+				t.Fatalf("Load() unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(p.migrations[tt.wantName], &Migration{fsys: p.migrations[tt.wantName].fsys, name: tt.wantName, upPath: tt.wantName + ".up.sql", downPath: tt.wantName + ".down.sql"}) {
+				t.Errorf("Load() paired %+v, want the created files", p.migrations[tt.wantName])
+			}
+		})
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "add users", want: "add_users"},
+		{in: "  Add   Users!! ", want: "add_users"},
+		{in: "v2-index on orders.id", want: "v2_index_on_orders_id"},
+		{in: "!!!", want: ""},
+	}
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,30 +1,98 @@
 package files
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/mertenvg/migrate"
 )
 
+// NoTransactionDirective marks a migration that must run outside a transaction when it appears in a comment at the
+// top of the up file, e.g.
+//
+//	-- migrate:no-transaction
+//	CREATE INDEX CONCURRENTLY ...
+const NoTransactionDirective = "migrate:no-transaction"
+
 type Provider struct {
 	position   int
 	names      []string
 	migrations map[string]*Migration
+	logger     *slog.Logger
+}
+
+type Option func(p *Provider)
+
+// WithLogger logs every migration found at debug level, and how many were loaded at info level, as structured
+// records with the migration name and files
+func WithLogger(l *slog.Logger) Option {
+	return func(p *Provider) {
+		if l != nil {
+			p.logger = l
+		}
+	}
+}
+
+// Load creates a Provider for the migration files in the directory at path
+func Load(path string, opts ...Option) (*Provider, error) {
+	p, err := load(os.DirFS(path), opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load migrations from '%v': %w", path, err)
+	}
+	return p, nil
+}
+
+// LoadFS creates a Provider for the migration files at the root of fsys, such as an embed.FS. Use fs.Sub to point it
+// at a subdirectory.
+func LoadFS(fsys fs.FS, opts ...Option) (*Provider, error) {
+	p, err := load(fsys, opts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load migrations: %w", err)
+	}
+	return p, nil
 }
 
-func NewProvider(path string) *Provider {
-	files, err := os.ReadDir(path)
+// NewProvider is like Load but panics if the migrations cannot be loaded
+func NewProvider(path string, opts ...Option) *Provider {
+	p, err := Load(path, opts...)
 	if err != nil {
-		panic(fmt.Errorf("cannot read dir '%v': %w", path, err))
+		panic(err)
+	}
+	return p
+}
+
+// NewFSProvider is like LoadFS but panics if the migrations cannot be loaded
+func NewFSProvider(fsys fs.FS, opts ...Option) *Provider {
+	p, err := LoadFS(fsys, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// load pairs the up and down files at the root of fsys
+func load(fsys fs.FS, opts []Option) (*Provider, error) {
+	p := &Provider{
+		logger: slog.New(slog.DiscardHandler),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read dir: %w", err)
 	}
 	var names []string
-	var downFiles []os.DirEntry
+	var downFiles []fs.DirEntry
 	migrations := make(map[string]*Migration)
 
 	for _, file := range files {
@@ -32,37 +100,56 @@ func NewProvider(path string) *Provider {
 			continue
 		}
 		fileName := file.Name()
-		if strings.HasSuffix(fileName, ".down.sql") {
+		name, down := migrationName(fileName)
+		if down {
 			downFiles = append(downFiles, file)
 			continue
 		}
-		name := fileName
-		if strings.HasSuffix(name, ".sql") {
-			name = strings.TrimSuffix(name, ".sql")
-		}
-		if strings.HasSuffix(name, ".up") {
-			name = strings.TrimSuffix(name, ".up")
+		noTx, err := hasDirective(fsys, fileName, NoTransactionDirective)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read migration file '%v': %w", fileName, err)
 		}
 		names = append(names, name)
 		migrations[name] = &Migration{
-			name:   name,
-			upPath: filepath.Join(path, fileName),
+			fsys:          fsys,
+			name:          name,
+			upPath:        fileName,
+			noTransaction: noTx,
 		}
 	}
 	slices.Sort(names)
 	for _, file := range downFiles {
 		fileName := file.Name()
-		name := strings.TrimSuffix(fileName, ".down.sql")
+		name, _ := migrationName(fileName)
 		migration, ok := migrations[name]
 		if !ok {
-			panic(fmt.Errorf("no matching 'up' migration found for '%v'", fileName))
+			return nil, fmt.Errorf("no matching 'up' migration found for '%v'", fileName)
 		}
-		migration.downPath = filepath.Join(path, fileName)
+		migration.downPath = fileName
+	}
+	for _, name := range names {
+		migration := migrations[name]
+		p.logger.LogAttrs(context.Background(), slog.LevelDebug, "found migration",
+			slog.String("migration", name),
+			slog.String("up", migration.upPath),
+			slog.String("down", migration.downPath),
+			slog.Bool("no_transaction", migration.noTransaction),
+		)
 	}
-	return &Provider{
-		names:      names,
-		migrations: migrations,
+	p.logger.LogAttrs(context.Background(), slog.LevelInfo, "loaded migrations", slog.Int("migrations", len(names)))
+
+	p.names = names
+	p.migrations = migrations
+	return p, nil
+}
+
+// migrationName returns the name of the migration a file belongs to, and whether it is the down file
+func migrationName(fileName string) (name string, down bool) {
+	if strings.HasSuffix(fileName, ".down.sql") {
+		return strings.TrimSuffix(fileName, ".down.sql"), true
 	}
+	name = strings.TrimSuffix(fileName, ".sql")
+	return strings.TrimSuffix(name, ".up"), false
 }
 
 func (p *Provider) Next() (migrate.Migration, error) {
@@ -79,45 +166,104 @@ func (p *Provider) Next() (migrate.Migration, error) {
 }
 
 type Migration struct {
-	name     string
-	upPath   string
-	downPath string
-	close    []io.Closer
+	fsys          fs.FS
+	name          string
+	upPath        string
+	downPath      string
+	noTransaction bool
+	close         []io.Closer
 }
 
 func (m *Migration) Name() string {
 	return m.name
 }
 
+// NoTransaction returns true if the up file starts with the NoTransactionDirective
+func (m *Migration) NoTransaction() bool {
+	return m.noTransaction
+}
+
+// Up returns the up file. If it cannot be opened the error is returned when reading from it, use OpenUp to get the
+// error straight away.
 func (m *Migration) Up() io.Reader {
-	if m.upPath == "" {
-		return bytes.NewBufferString("")
-	}
-	file, err := os.Open(m.upPath)
-	if err != nil {
-		panic(fmt.Errorf("cannot open migration file '%v': %w", m.upPath, err))
-	}
-	m.close = append(m.close, file)
-	return file
+	return orErrReader(m.OpenUp())
 }
 
+// Down returns the down file, or an empty reader if there is none. If it cannot be opened the error is returned when
+// reading from it, use OpenDown to get the error straight away.
 func (m *Migration) Down() io.Reader {
-	if m.downPath == "" {
-		return bytes.NewBufferString("")
+	return orErrReader(m.OpenDown())
+}
+
+// OpenUp opens the up file
+func (m *Migration) OpenUp() (io.Reader, error) {
+	return m.open(m.upPath)
+}
+
+// OpenDown opens the down file, or returns an empty reader if there is none
+func (m *Migration) OpenDown() (io.Reader, error) {
+	return m.open(m.downPath)
+}
+
+func (m *Migration) open(path string) (io.Reader, error) {
+	if path == "" {
+		return bytes.NewBufferString(""), nil
 	}
-	file, err := os.Open(m.downPath)
+	file, err := m.fsys.Open(path)
 	if err != nil {
-		panic(fmt.Errorf("cannot open migration file '%v': %w", m.downPath, err))
+		return nil, fmt.Errorf("cannot open migration file '%v': %w", path, err)
 	}
 	m.close = append(m.close, file)
-	return file
+	return file, nil
 }
 
+// Close closes the files opened by Up, Down, OpenUp and OpenDown. The files are only read from so errors closing them
+// are ignored.
 func (m *Migration) Close() {
 	for _, file := range m.close {
-		err := file.Close()
-		if err != nil {
-			panic(fmt.Errorf("cannot close migration file '%v': %w", m.name, err))
+		_ = file.Close()
+	}
+	m.close = nil
+}
+
+// errReader returns err on every Read
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+func orErrReader(r io.Reader, err error) io.Reader {
+	if err != nil {
+		return errReader{err: err}
+	}
+	return r
+}
+
+// hasDirective returns true if the directive appears in the leading comment lines of the file
+func hasDirective(fsys fs.FS, path, directive string) (bool, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		comment, ok := strings.CutPrefix(line, "--")
+		if !ok {
+			// the header ends at the first statement
+			break
+		}
+		if strings.TrimSpace(comment) == directive {
+			return true, nil
 		}
 	}
+	return false, scanner.Err()
 }
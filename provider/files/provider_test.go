@@ -2,14 +2,49 @@ package files
 
 import (
 	"bytes"
+	"embed"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+
+	"github.com/mertenvg/migrate"
 )
 
+//go:embed testdata
+var testdata embed.FS
+
 func TestNewProvider(t *testing.T) {
-	p := NewProvider("./testdata")
-	fmt.Println(p.names)
+	validateProvider(t, NewProvider("./testdata"))
+}
+
+func TestNewFSProvider(t *testing.T) {
+	fsys, err := fs.Sub(testdata, "testdata")
+	if err != nil {
+		t.Fatalf("Sub() unexpected error %v", err)
+	}
+	validateProvider(t, NewFSProvider(fsys))
+}
+
+func TestNewFSProvider_WithOrphanDown(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("NewFSProvider() expected panic for orphan down file")
+		}
+	}()
+	NewFSProvider(fstest.MapFS{
+		"00001.up.sql":   {Data: []byte("00001.up")},
+		"00002.down.sql": {Data: []byte("00002.down")},
+	})
+}
+
+func validateProvider(t *testing.T, p *Provider) {
+	t.Helper()
 	wanted := []string{
 		"00001",
 		"00002",
@@ -55,3 +90,144 @@ func TestNewProvider(t *testing.T) {
 		m.Close()
 	}
 }
+
+func TestNewProvider_NoTransaction(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"00001.up.sql": "-- migrate:no-transaction\nCREATE INDEX CONCURRENTLY test_idx ON test (id);",
+		"00002.up.sql": "-- creates the index\n\n--migrate:no-transaction\nCREATE INDEX CONCURRENTLY test_idx ON test (id);",
+		"00003.up.sql": "CREATE TABLE test (id INT);\n-- migrate:no-transaction",
+		"00004.up.sql": "-- migrate:no-transaction-please\nCREATE TABLE test (id INT);",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() unexpected error %v", err)
+		}
+	}
+	want := map[string]bool{
+		"00001": true,
+		"00002": true,
+		"00003": false,
+		"00004": false,
+	}
+
+	p := NewProvider(dir)
+	for {
+		m, err := p.Next()
+		if err != nil {
+			t.Fatalf("Next() unexpected error %v", err)
+		}
+		if m == nil {
+			break
+		}
+		nt, ok := m.(migrate.NonTransactional)
+		if !ok {
+			t.Fatalf("Next() migration does not implement NonTransactional")
+		}
+		if got := nt.NoTransaction(); got != want[m.Name()] {
+			t.Errorf("NoTransaction() for %s = %v, want %v", m.Name(), got, want[m.Name()])
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{
+			name: "load testdata",
+			path: "./testdata",
+		},
+		{
+			name:    "load missing dir",
+			path:    "./missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Load(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (p == nil) != tt.wantErr {
+				t.Errorf("Load() = %v, wantErr %v", p, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFS_WithOrphanDown(t *testing.T) {
+	_, err := LoadFS(fstest.MapFS{
+		"00001.up.sql":   {Data: []byte("00001.up")},
+		"00002.down.sql": {Data: []byte("00002.down")},
+	})
+	if err == nil {
+		t.Errorf("LoadFS() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestMigration_OpenUp_WithMissingFile(t *testing.T) {
+	m := &Migration{
+		fsys:     fstest.MapFS{},
+		name:     "00001",
+		upPath:   "00001.up.sql",
+		downPath: "00001.down.sql",
+	}
+	if _, err := m.OpenUp(); err == nil {
+		t.Errorf("OpenUp() error = %v, wantErr %v", err, true)
+	}
+	if _, err := m.OpenDown(); err == nil {
+		t.Errorf("OpenDown() error = %v, wantErr %v", err, true)
+	}
+	if _, err := io.ReadAll(m.Up()); err == nil {
+		t.Errorf("ReadAll(Up()) error = %v, wantErr %v", err, true)
+	}
+	if _, err := io.ReadAll(m.Down()); err == nil {
+		t.Errorf("ReadAll(Down()) error = %v, wantErr %v", err, true)
+	}
+	m.Close()
+}
+
+func TestMigration_Close_Twice(t *testing.T) {
+	p, err := Load("./testdata")
+	if err != nil {
+		t.Fatalf("Load() unexpected error %v", err)
+	}
+	m, err := p.Next()
+	if err != nil {
+		t.Fatalf("Next() unexpected error %v", err)
+	}
+	for range 2 {
+		if _, err := io.ReadAll(m.Up()); err != nil {
+			t.Errorf("ReadAll(Up()) unexpected error %v", err)
+		}
+		m.Close()
+	}
+}
+
+func TestLoadFS_WithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	_, err := LoadFS(fstest.MapFS{
+		"001_init.up.sql":    {Data: []byte("-- " + NoTransactionDirective + "\nCREATE INDEX CONCURRENTLY aaa;")},
+		"001_init.down.sql":  {Data: []byte("DROP INDEX aaa;")},
+		"002_users.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+		"002_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	}, WithLogger(logger))
+	if err != nil {
+		t.Fatalf("LoadFS() unexpected error %v", err)
+	}
+
+	for _, want := range []string{
+		"msg=\"found migration\" migration=001_init up=001_init.up.sql down=001_init.down.sql no_transaction=true",
+		"msg=\"found migration\" migration=002_users up=002_users.up.sql down=002_users.down.sql no_transaction=false",
+		"msg=\"loaded migrations\" migrations=2",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("LoadFS() logged %q, want it to contain %q", buf.String(), want)
+		}
+	}
+}
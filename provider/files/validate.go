@@ -0,0 +1,208 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/mertenvg/migrate/pkg/reader"
+)
+
+// Finding is a problem Validate found with a migration file
+type Finding struct {
+	File string
+	// Line the problem is on, 0 if it concerns the whole file
+	Line    int
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s", f.File, f.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.Message)
+}
+
+type validateOptions struct {
+	requireDown bool
+}
+
+type ValidateOption func(o *validateOptions)
+
+// WithRequireDown reports migrations without a down file
+func WithRequireDown() ValidateOption {
+	return func(o *validateOptions) {
+		o.requireDown = true
+	}
+}
+
+// Validate checks the migration files at the root of fsys without a database, reporting:
+//   - files that are not .sql files but would be loaded as migrations
+//   - up files for the same migration name, such as foo.sql and foo.up.sql
+//   - down files without a matching up file
+//   - migrations without a down file, if WithRequireDown is given
+//   - names that do not start with a number, numbers with a different width than most others, and numbers used by
+//     more than one migration
+//   - up files without statements
+//   - unterminated quoted strings, comments and COPY data blocks
+//   - BEGIN, START TRANSACTION, COMMIT and END statements, which the adapters skip as they begin and commit
+//     transactions themselves
+//
+// The findings are sorted by file and line. An error is only returned if the files cannot be read.
+func Validate(fsys fs.FS, opts ...ValidateOption) ([]Finding, error) {
+	o := &validateOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read dir: %w", err)
+	}
+
+	var findings []Finding
+	ups := map[string]string{}
+	var downs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		fileName := entry.Name()
+		name, down := migrationName(fileName)
+		if !strings.HasSuffix(fileName, ".sql") {
+			findings = append(findings, Finding{File: fileName, Message: fmt.Sprintf("not a .sql file, it would be loaded as migration '%v'", name)})
+		}
+		if down {
+			downs = append(downs, fileName)
+			continue
+		}
+		if other, ok := ups[name]; ok {
+			findings = append(findings, Finding{File: fileName, Message: fmt.Sprintf("duplicate migration '%v', also defined by '%v'", name, other)})
+			continue
+		}
+		ups[name] = fileName
+	}
+
+	hasDown := map[string]bool{}
+	for _, fileName := range downs {
+		name, _ := migrationName(fileName)
+		if _, ok := ups[name]; !ok {
+			findings = append(findings, Finding{File: fileName, Message: "no matching up file"})
+			continue
+		}
+		hasDown[name] = true
+	}
+
+	names := make([]string, 0, len(ups))
+	for name := range ups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	if o.requireDown {
+		for _, name := range names {
+			if !hasDown[name] {
+				findings = append(findings, Finding{File: ups[name], Message: "no down file"})
+			}
+		}
+	}
+	findings = append(findings, checkNumbering(names, ups)...)
+
+	for _, name := range names {
+		f, err := checkStatements(fsys, ups[name], true)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, f...)
+	}
+	for _, fileName := range downs {
+		f, err := checkStatements(fsys, fileName, false)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, f...)
+	}
+
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		if c := strings.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		return a.Line - b.Line
+	})
+	return findings, nil
+}
+
+// checkNumbering reports names that do not start with digits, numbers with a different width than most others and
+// numbers used by more than one migration. Names must be sorted.
+func checkNumbering(names []string, files map[string]string) []Finding {
+	var findings []Finding
+	widths := map[int]int{}
+	numbers := map[string]string{}
+	for _, name := range names {
+		number := leadingNumber(name)
+		if number == "" {
+			findings = append(findings, Finding{File: files[name], Message: fmt.Sprintf("migration '%v' does not start with a number", name)})
+			continue
+		}
+		if other, ok := numbers[number]; ok {
+			findings = append(findings, Finding{File: files[name], Message: fmt.Sprintf("number %v is also used by migration '%v'", number, other)})
+		} else {
+			numbers[number] = name
+		}
+		widths[len(number)]++
+	}
+
+	// the most common width is taken to be the intended one, preferring the widest on a tie
+	common := 0
+	for width, n := range widths {
+		if n > widths[common] || (n == widths[common] && width > common) {
+			common = width
+		}
+	}
+	for _, name := range names {
+		number := leadingNumber(name)
+		if _, ok := numbers[number]; ok && len(number) != common {
+			findings = append(findings, Finding{File: files[name], Message: fmt.Sprintf("number %v has %d digits, most migrations use %d", number, len(number), common)})
+		}
+	}
+	return findings
+}
+
+// checkStatements reads every statement in the file, reporting syntax errors and transaction control statements, and
+// an up file without statements
+func checkStatements(fsys fs.FS, fileName string, up bool) ([]Finding, error) {
+	f, err := fsys.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open migration file '%v': %w", fileName, err)
+	}
+	defer f.Close()
+
+	var findings []Finding
+	r := reader.NewSQLReader(f)
+	statements := 0
+	for {
+		q, err := r.Next()
+		var se *reader.SyntaxError
+		if errors.As(err, &se) {
+			findings = append(findings, Finding{File: fileName, Line: se.Line, Message: se.Msg})
+			return findings, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read migration file '%v': %w", fileName, err)
+		}
+		if q == "" {
+			break
+		}
+		if reader.TransactionControl(q) {
+			findings = append(findings, Finding{File: fileName, Line: r.Line(), Message: fmt.Sprintf("%v is skipped, the adapter begins and commits transactions itself", strings.ToUpper(q))})
+			continue
+		}
+		statements++
+	}
+	if up && statements == 0 {
+		findings = append(findings, Finding{File: fileName, Message: "no statements"})
+	}
+	return findings, nil
+}
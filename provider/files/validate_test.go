@@ -0,0 +1,111 @@
+package files
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		opts []ValidateOption
+		want []Finding
+	}{
+		{
+			name: "valid migrations",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":     {Data: []byte("CREATE TABLE a ();\n")},
+				"0001_init.down.sql":   {Data: []byte("DROP TABLE a;\n")},
+				"0002_users.sql":       {Data: []byte("CREATE TABLE users ();\n")},
+				"0003_orders.up.sql":   {Data: []byte("-- " + NoTransactionDirective + "\nCREATE INDEX CONCURRENTLY i ON a (id);\n")},
+				"0003_orders.down.sql": {Data: []byte("")},
+			},
+		},
+		{
+			name: "file problems",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":    {Data: []byte("CREATE TABLE a ();\n")},
+				"0001_init.sql":       {Data: []byte("CREATE TABLE a ();\n")},
+				"0002_gone.down.sql":  {Data: []byte("DROP TABLE gone;\n")},
+				"0003_empty.up.sql":   {Data: []byte("-- nothing to see here\n")},
+				"0003_empty.down.sql": {Data: []byte("")},
+			},
+			opts: []ValidateOption{WithRequireDown()},
+			want: []Finding{
+				{File: "0001_init.sql", Message: "no down file"},
+				{File: "0001_init.up.sql", Message: "duplicate migration '0001_init', also defined by '0001_init.sql'"},
+				{File: "0002_gone.down.sql", Message: "no matching up file"},
+				{File: "0003_empty.up.sql", Message: "no statements"},
+			},
+		},
+		{
+			name: "numbering problems",
+			fsys: fstest.MapFS{
+				"0001_init.sql":            {Data: []byte("SELECT 1;")},
+				"0002_users.sql":           {Data: []byte("SELECT 1;")},
+				"0002_orders.sql":          {Data: []byte("SELECT 1;")},
+				"20240506070809_items.sql": {Data: []byte("SELECT 1;")},
+				"extra.sql":                {Data: []byte("SELECT 1;")},
+				"notes.txt":                {Data: []byte("SELECT 1;")},
+			},
+			want: []Finding{
+				{File: "0002_users.sql", Message: "number 0002 is also used by migration '0002_orders'"},
+				{File: "20240506070809_items.sql", Message: "number 20240506070809 has 14 digits, most migrations use 4"},
+				{File: "extra.sql", Message: "migration 'extra' does not start with a number"},
+				{File: "notes.txt", Message: "not a .sql file, it would be loaded as migration 'notes.txt'"},
+				{File: "notes.txt", Message: "migration 'notes.txt' does not start with a number"},
+			},
+		},
+		{
+			name: "numbers followed by other separators",
+			fsys: fstest.MapFS{
+				"0001.up.sql":                  {Data: []byte("SELECT 1;")},
+				"0002.some-description.sql":    {Data: []byte("SELECT 1;")},
+				"0003-orders.sql":              {Data: []byte("SELECT 1;")},
+				"0003.some-description.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			want: []Finding{
+				{File: "0003.some-description.up.sql", Message: "number 0003 is also used by migration '0003-orders'"},
+			},
+		},
+		{
+			name: "statement problems",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":   {Data: []byte("BEGIN;\nCREATE TABLE a ();\nCOMMIT;\nSTART TRANSACTION;\n")},
+				"0001_init.down.sql": {Data: []byte("DROP TABLE a;\n\nINSERT INTO b VALUES ('never\ncloses);\n")},
+				"0002_func.sql":      {Data: []byte("CREATE FUNCTION f() RETURNS void AS $$\nBEGIN\nEND;\n")},
+				"0003_comment.sql":   {Data: []byte("SELECT 1;\n/* never closes\n")},
+			},
+			want: []Finding{
+				{File: "0001_init.down.sql", Line: 3, Message: "unterminated quoted string"},
+				{File: "0001_init.up.sql", Line: 1, Message: "BEGIN is skipped, the adapter begins and commits transactions itself"},
+				{File: "0001_init.up.sql", Line: 3, Message: "COMMIT is skipped, the adapter begins and commits transactions itself"},
+				{File: "0001_init.up.sql", Line: 4, Message: "START TRANSACTION is skipped, the adapter begins and commits transactions itself"},
+				{File: "0002_func.sql", Line: 1, Message: "unterminated dollar-quoted string"},
+				{File: "0003_comment.sql", Line: 2, Message: "unterminated block comment"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Validate(tt.fsys, tt.opts...)
+			if err != nil {
+				t.Fatalf("Validate() unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinding_String(t *testing.T) {
+	if got := (Finding{File: "0001_init.sql", Message: "no statements"}).String(); got != "0001_init.sql: no statements" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := (Finding{File: "0001_init.sql", Line: 3, Message: "unterminated quoted string"}).String(); got != "0001_init.sql:3: unterminated quoted string" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+package funcs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/mertenvg/migrate"
+)
+
+// Provider returns migrations written in Go, merged in name order with the migrations of an optional base provider
+type Provider struct {
+	base       migrate.Provider
+	names      []string
+	migrations map[string]*Migration
+	position   int
+	started    bool
+	// next is the migration read from the base provider that has not been returned yet
+	next migrate.Migration
+}
+
+// NewProvider creates a Provider merging the registered Go migrations with those of base, which may be nil. base must
+// return its migrations in name order, as the files provider does.
+func NewProvider(base migrate.Provider) *Provider {
+	return &Provider{
+		base:       base,
+		migrations: make(map[string]*Migration),
+	}
+}
+
+// Register adds a Go migration. down may be nil if the migration cannot be taken down. Migrations must be registered
+// before the first call to Next.
+func (p *Provider) Register(name string, up, down migrate.MigrationFunc) error {
+	if p.started {
+		return fmt.Errorf("cannot register migration '%v' after migrations have been read", name)
+	}
+	if up == nil {
+		return fmt.Errorf("cannot register migration '%v' without an up func", name)
+	}
+	if _, ok := p.migrations[name]; ok {
+		return fmt.Errorf("migration '%v' is already registered", name)
+	}
+	p.names = append(p.names, name)
+	p.migrations[name] = &Migration{
+		name: name,
+		up:   up,
+		down: down,
+	}
+	return nil
+}
+
+func (p *Provider) Next() (migrate.Migration, error) {
+	if !p.started {
+		slices.Sort(p.names)
+		p.started = true
+	}
+	if p.next == nil && p.base != nil {
+		next, err := p.base.Next()
+		if err != nil {
+			return nil, err
+		}
+		p.next = next
+	}
+
+	var own *Migration
+	if p.position < len(p.names) {
+		own = p.migrations[p.names[p.position]]
+	}
+
+	switch {
+	case own == nil && p.next == nil:
+		return nil, nil
+	case own == nil:
+		return p.take(), nil
+	case p.next == nil || own.name < p.next.Name():
+		p.position++
+		return own, nil
+	case own.name == p.next.Name():
+		return nil, fmt.Errorf("migration '%v' is registered and provided by the base provider", own.name)
+	default:
+		return p.take(), nil
+	}
+}
+
+// take returns the pending migration from the base provider
+func (p *Provider) take() migrate.Migration {
+	next := p.next
+	p.next = nil
+	return next
+}
+
+// Migration is a migration written in Go
+type Migration struct {
+	name string
+	up   migrate.MigrationFunc
+	down migrate.MigrationFunc
+}
+
+func (m *Migration) Name() string {
+	return m.name
+}
+
+// Up returns an empty reader, the migration is applied by UpFunc
+func (m *Migration) Up() io.Reader {
+	return bytes.NewBufferString("")
+}
+
+// Down returns an empty reader, the migration is taken down by DownFunc
+func (m *Migration) Down() io.Reader {
+	return bytes.NewBufferString("")
+}
+
+func (m *Migration) UpFunc() migrate.MigrationFunc {
+	return m.up
+}
+
+func (m *Migration) DownFunc() migrate.MigrationFunc {
+	return m.down
+}
+
+func (m *Migration) Close() {
+	// nothing to close
+}
@@ -0,0 +1,117 @@
+package funcs
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/mertenvg/migrate"
+	"github.com/mertenvg/migrate/provider/files"
+)
+
+func noop(ctx context.Context, tx *sql.Tx) error {
+	return nil
+}
+
+func TestProvider_Next(t *testing.T) {
+	p := NewProvider(files.NewProvider("../files/testdata"))
+	for _, name := range []string{"00004.transform", "00000.seed", "00006.reencode"} {
+		if err := p.Register(name, noop, nil); err != nil {
+			t.Fatalf("Register() unexpected error %v", err)
+		}
+	}
+
+	want := []string{
+		"00000.seed",
+		"00001",
+		"00002",
+		"00003",
+		"00004",
+		"00004.transform",
+		"00005.some-description",
+		"00006.reencode",
+	}
+	wantFunc := map[string]bool{
+		"00000.seed":      true,
+		"00004.transform": true,
+		"00006.reencode":  true,
+	}
+
+	var got []string
+	for {
+		m, err := p.Next()
+		if err != nil {
+			t.Fatalf("Next() unexpected error %v", err)
+		}
+		if m == nil {
+			break
+		}
+		got = append(got, m.Name())
+		if _, ok := m.(migrate.FuncMigration); ok != wantFunc[m.Name()] {
+			t.Errorf("Next() %s is FuncMigration = %v, want %v", m.Name(), ok, wantFunc[m.Name()])
+		}
+		m.Close()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Next() names = %v, want %v", got, want)
+	}
+}
+
+func TestProvider_Next_WithoutBase(t *testing.T) {
+	p := NewProvider(nil)
+	if err := p.Register("bbb", noop, noop); err != nil {
+		t.Fatalf("Register() unexpected error %v", err)
+	}
+	if err := p.Register("aaa", noop, nil); err != nil {
+		t.Fatalf("Register() unexpected error %v", err)
+	}
+
+	for _, want := range []string{"aaa", "bbb"} {
+		m, err := p.Next()
+		if err != nil {
+			t.Fatalf("Next() unexpected error %v", err)
+		}
+		if m == nil || m.Name() != want {
+			t.Fatalf("Next() = %v, want %v", m, want)
+		}
+	}
+	m, err := p.Next()
+	if m != nil || err != nil {
+		t.Errorf("Next() = %v, %v, want nil, nil", m, err)
+	}
+}
+
+func TestProvider_Next_WithDuplicate(t *testing.T) {
+	p := NewProvider(files.NewProvider("../files/testdata"))
+	if err := p.Register("00002", noop, nil); err != nil {
+		t.Fatalf("Register() unexpected error %v", err)
+	}
+
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		_, err = p.Next()
+	}
+	if err == nil {
+		t.Errorf("Next() error = %v, wantErr %v", err, true)
+	}
+}
+
+func TestProvider_Register(t *testing.T) {
+	p := NewProvider(nil)
+	if err := p.Register("aaa", noop, nil); err != nil {
+		t.Errorf("Register() error = %v, wantErr %v", err, false)
+	}
+	if err := p.Register("aaa", noop, nil); err == nil {
+		t.Errorf("Register() duplicate error = %v, wantErr %v", err, true)
+	}
+	if err := p.Register("bbb", nil, noop); err == nil {
+		t.Errorf("Register() without up error = %v, wantErr %v", err, true)
+	}
+	if _, err := p.Next(); err != nil {
+		t.Fatalf("Next() unexpected error %v", err)
+	}
+	if err := p.Register("ccc", noop, nil); err == nil {
+		t.Errorf("Register() after Next error = %v, wantErr %v", err, true)
+	}
+}
@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Backoff returns how long to wait before the given retry, starting at 1
+type Backoff func(retry int) time.Duration
+
+// ConstantBackoff waits d before every retry
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff waits base before the first retry, doubling the wait for every retry after that up to max. There
+// is no upper bound if max is 0.
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && (max == 0 || d < max); i++ {
+			d *= 2
+		}
+		if max > 0 && d > max {
+			return max
+		}
+		return d
+	}
+}
+
+// RetryPolicy decides how often a transaction that failed with a retryable error is run again. Retrying is only done
+// for transactions, so with TxSingle the whole batch is retried, with TxPerMigration only the failed migration, and
+// nothing is retried with TxNone, for migrations implementing NonTransactional, or once the adapter reported an
+// *IncompleteError.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a transaction is run in total, values below 2 disable retrying
+	MaxAttempts int
+	// Backoff returns how long to wait before each retry, retrying immediately if nil
+	Backoff Backoff
+	// Retryable returns true if an error is worth retrying. Defaults to the adapter's RetryClassifier, without which
+	// nothing is retried.
+	Retryable func(err error) bool
+}
+
+// retryable returns true if the error is worth retrying according to the policy or the adapter
+func (m *Migrate) retryable(err error) bool {
+	if m.retry.Retryable != nil {
+		return m.retry.Retryable(err)
+	}
+	if rc, ok := capability[RetryClassifier](m); ok {
+		return rc.Retryable(err)
+	}
+	return false
+}
+
+// withRetry runs f, running it again according to the retry policy for as long as it fails with a retryable error
+func (m *Migrate) withRetry(ctx context.Context, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= m.retry.MaxAttempts || !m.retryable(err) {
+			return err
+		}
+		// migrations the adapter committed before failing would be applied again
+		var ie *IncompleteError
+		if errors.As(err, &ie) {
+			return err
+		}
+
+		var wait time.Duration
+		if m.retry.Backoff != nil {
+			wait = m.retry.Backoff(attempt)
+		}
+		m.logAttrs(ctx, slog.LevelWarn, "retrying transaction",
+			slog.Int("attempt", attempt),
+			slog.Int("max_attempts", m.retry.MaxAttempts),
+			slog.Duration("backoff", wait),
+			slog.Any("error", err),
+		)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d or until the context is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	ctx = orBackground(ctx)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
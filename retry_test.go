@@ -0,0 +1,177 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var errRetryable = errors.New("could not obtain lock")
+
+type MockRetryAdapter struct {
+	MockAdapter
+	// failures is the number of times up fails with errRetryable for each migration
+	failures map[string]int
+	// incomplete lists the migrations the adapter reports having committed when up fails for a migration
+	incomplete map[string][]string
+}
+
+func (m *MockRetryAdapter) Up(name string, up, down io.Reader) error {
+	if m.failures[name] > 0 {
+		m.failures[name]--
+		m.calls = append(m.calls, "up "+name)
+		if committed, ok := m.incomplete[name]; ok {
+			return &IncompleteError{Committed: committed, Err: errRetryable}
+		}
+		return errRetryable
+	}
+	return m.MockAdapter.Up(name, up, down)
+}
+
+func (m *MockRetryAdapter) Retryable(err error) bool {
+	return errors.Is(err, errRetryable)
+}
+
+func TestMigrate_Migrate_WithRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		txMode    TxMode
+		policy    RetryPolicy
+		failures  map[string]int
+		wantErr   bool
+		wantCalls []string
+	}{
+		{
+			name:      "no retry policy",
+			failures:  map[string]int{"bbb": 1},
+			wantErr:   true,
+			wantCalls: []string{"begin", "up aaa", "up bbb", "rollback"},
+		},
+		{
+			name:      "single transaction retries the batch",
+			policy:    RetryPolicy{MaxAttempts: 3},
+			failures:  map[string]int{"bbb": 2},
+			wantCalls: []string{"begin", "up aaa", "up bbb", "rollback", "begin", "up aaa", "up bbb", "rollback", "begin", "up aaa", "up bbb", "commit"},
+		},
+		{
+			name:      "attempts exhausted",
+			policy:    RetryPolicy{MaxAttempts: 2, Backoff: ConstantBackoff(time.Millisecond)},
+			failures:  map[string]int{"bbb": 2},
+			wantErr:   true,
+			wantCalls: []string{"begin", "up aaa", "up bbb", "rollback", "begin", "up aaa", "up bbb", "rollback"},
+		},
+		{
+			name:      "transaction per migration retries the failed migration",
+			txMode:    TxPerMigration,
+			policy:    RetryPolicy{MaxAttempts: 3},
+			failures:  map[string]int{"bbb": 1},
+			wantCalls: []string{"begin", "up aaa", "commit", "begin", "up bbb", "rollback", "begin", "up bbb", "commit"},
+		},
+		{
+			name:      "no transaction is not retried",
+			txMode:    TxNone,
+			policy:    RetryPolicy{MaxAttempts: 3},
+			failures:  map[string]int{"bbb": 1},
+			wantErr:   true,
+			wantCalls: []string{"up aaa", "up bbb"},
+		},
+		{
+			name:      "policy classifier overrides the adapter",
+			policy:    RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool { return false }},
+			failures:  map[string]int{"bbb": 1},
+			wantErr:   true,
+			wantCalls: []string{"begin", "up aaa", "up bbb", "rollback"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockRetryAdapter{failures: tt.failures}
+			m := New(
+				WithAdapter(a),
+				WithProvider(&MockProvider{names: []string{"aaa", "bbb"}}),
+				WithTxMode(tt.txMode),
+				WithRetryPolicy(tt.policy),
+			)
+			err := m.Migrate(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(a.calls, tt.wantCalls) {
+				t.Errorf("Migrate() calls = %v, want %v", a.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMigrate_Migrate_WithAdapterIncompleteError(t *testing.T) {
+	// the adapter committed ccc itself before ddd failed, which must not be retried and is listed after the groups
+	// committed before
+	a := &MockRetryAdapter{
+		failures:   map[string]int{"ddd": 1},
+		incomplete: map[string][]string{"ddd": {"ccc"}},
+	}
+	m := New(
+		WithAdapter(a),
+		WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc", "ddd"}, noTx: []string{"bbb"}}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3}),
+	)
+	err := m.Migrate(context.Background())
+	var incompleteErr *IncompleteError
+	if !errors.As(err, &incompleteErr) || !reflect.DeepEqual(incompleteErr.Committed, []string{"aaa", "bbb", "ccc"}) {
+		t.Errorf("Migrate() error = %v, want IncompleteError for [aaa bbb ccc]", err)
+	}
+	wantCalls := []string{"begin", "up aaa", "commit", "up bbb", "begin", "up ccc", "up ddd", "rollback"}
+	if !reflect.DeepEqual(a.calls, wantCalls) {
+		t.Errorf("Migrate() calls = %v, want %v", a.calls, wantCalls)
+	}
+}
+
+func TestMigrate_Migrate_WithRetryCancelled(t *testing.T) {
+	a := &MockRetryAdapter{failures: map[string]int{"aaa": 1}}
+	m := New(
+		WithAdapter(a),
+		WithProvider(&MockProvider{names: []string{"aaa"}}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Hour)}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := m.Migrate(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Migrate() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    time.Duration
+		max     time.Duration
+		retries []time.Duration
+	}{
+		{
+			name:    "without max",
+			base:    time.Second,
+			retries: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:    "with max",
+			base:    time.Second,
+			max:     3 * time.Second,
+			retries: []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backoff := ExponentialBackoff(tt.base, tt.max)
+			for i, want := range tt.retries {
+				if got := backoff(i + 1); got != want {
+					t.Errorf("ExponentialBackoff()(%d) = %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
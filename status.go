@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"context"
+	"slices"
+)
+
+// State of a migration when comparing the provider with the applied migrations
+type State string
+
+const (
+	// StateApplied migrations are known to the provider and have been applied
+	StateApplied State = "applied"
+	// StatePending migrations are known to the provider and have not been applied yet
+	StatePending State = "pending"
+	// StateOutOfOrder migrations have not been applied yet but are ordered before a migration that has been, so
+	// applying them now would run them out of order
+	StateOutOfOrder State = "out-of-order"
+	// StateMissing migrations have been applied but are no longer known to the provider
+	StateMissing State = "missing"
+)
+
+// MigrationStatus is the State of a single migration
+type MigrationStatus struct {
+	Name  string
+	State State
+}
+
+// Status compares the provider's migrations with the applied migrations and reports the State of each. Migrations
+// known to the provider are listed in provider order, followed by any missing migrations. If the adapter is an
+// Inspector, its store is not created.
+func (m *Migrate) Status(ctx context.Context) ([]MigrationStatus, error) {
+	s, err := m.inspect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// find the position of the last applied migration the provider still knows about
+	last := -1
+	for i, name := range s.names {
+		if slices.Contains(s.applied, name) {
+			last = i
+		}
+	}
+
+	status := make([]MigrationStatus, 0, len(s.names)+len(s.down))
+	for i, name := range s.names {
+		state := StatePending
+		switch {
+		case slices.Contains(s.applied, name):
+			state = StateApplied
+		case i < last:
+			state = StateOutOfOrder
+		}
+		status = append(status, MigrationStatus{Name: name, State: state})
+	}
+	for _, name := range s.down {
+		status = append(status, MigrationStatus{Name: name, State: StateMissing})
+	}
+
+	return status, nil
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMigrate_Status(t *testing.T) {
+	tests := []struct {
+		name    string
+		m       *Migrate
+		want    []MigrationStatus
+		wantErr bool
+	}{
+		{
+			name:    "status with nothing",
+			m:       New(),
+			wantErr: true,
+		},
+		{
+			name: "status with no migrations",
+			m:    New(WithAdapter(&MockAdapter{}), WithProvider(&MockProvider{})),
+			want: []MigrationStatus{},
+		},
+		{
+			name: "status with applied and pending migrations",
+			m:    New(WithAdapter(&MockAdapter{applied: []string{"aaa"}}), WithProvider(&MockProvider{names: []string{"aaa", "bbb"}})),
+			want: []MigrationStatus{
+				{Name: "aaa", State: StateApplied},
+				{Name: "bbb", State: StatePending},
+			},
+		},
+		{
+			name: "status with out of order migrations",
+			m:    New(WithAdapter(&MockAdapter{applied: []string{"aaa", "ccc"}}), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc", "ddd"}})),
+			want: []MigrationStatus{
+				{Name: "aaa", State: StateApplied},
+				{Name: "bbb", State: StateOutOfOrder},
+				{Name: "ccc", State: StateApplied},
+				{Name: "ddd", State: StatePending},
+			},
+		},
+		{
+			name: "status with missing migrations",
+			m:    New(WithAdapter(&MockAdapter{applied: []string{"aaa", "bbb"}}), WithProvider(&MockProvider{names: []string{"aaa"}})),
+			want: []MigrationStatus{
+				{Name: "aaa", State: StateApplied},
+				{Name: "bbb", State: StateMissing},
+			},
+		},
+		{
+			name:    "status with adapter list error",
+			m:       New(WithAdapter(&MockAdapter{listErr: fmt.Errorf("fail list")}), WithProvider(&MockProvider{names: []string{"aaa"}})),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.m.Status(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Status() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Status() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// UpTo applies pending migrations in provider order up to and including the named migration. Applied migrations that
+// are missing from the provider are left alone.
+func (m *Migrate) UpTo(ctx context.Context, name string) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verifyChecksums(ctx, s); err != nil {
+		return err
+	}
+	target := slices.Index(s.names, name)
+	if target < 0 {
+		return fmt.Errorf("migration '%v' not found in provider", name)
+	}
+
+	var up []string
+	for _, pending := range s.up {
+		if slices.Index(s.names, pending) <= target {
+			up = append(up, pending)
+		}
+	}
+	if len(up) == 0 {
+		return nil
+	}
+	return m.run(ctx, s, nil, up)
+}
+
+// DownTo takes down every migration applied after the named migration, most recently applied first, using the rollback
+// saved when each was applied. The named migration stays applied. Migrations are ordered by name if the adapter is not
+// an AppliedLister.
+func (m *Migrate) DownTo(ctx context.Context, name string) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	target := slices.Index(s.order, name)
+	if target < 0 {
+		return fmt.Errorf("migration '%v' has not been applied", name)
+	}
+	return m.takeDown(ctx, s, len(s.order)-target-1)
+}
+
+// Rollback takes down the last steps applied migrations, most recently applied first, using the rollback saved when
+// each was applied. Migrations are ordered by name if the adapter is not an AppliedLister.
+func (m *Migrate) Rollback(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("cannot roll back %d migrations", steps)
+	}
+	if err := m.validate(); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	if steps > len(s.order) {
+		return fmt.Errorf("cannot roll back %d migrations, only %d applied", steps, len(s.order))
+	}
+	return m.takeDown(ctx, s, steps)
+}
+
+// takeDown takes down the last steps applied migrations in reverse order
+func (m *Migrate) takeDown(ctx context.Context, s *state, steps int) error {
+	if steps == 0 {
+		return nil
+	}
+	down := slices.Clone(s.order[len(s.order)-steps:])
+	slices.Reverse(down)
+	return m.run(ctx, s, down, nil)
+}
+
+// Redo takes down the most recently applied migration and applies it again, in the same transaction unless the TxMode or the
+// migration says otherwise. Its checksum is not verified, so a migration can be edited and redone during development.
+func (m *Migrate) Redo(ctx context.Context) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := m.prepare(ctx)
+	if err != nil {
+		return err
+	}
+	if len(s.order) == 0 {
+		return fmt.Errorf("cannot redo, no migrations applied")
+	}
+	last := s.order[len(s.order)-1]
+	if _, ok := s.migrations[last]; !ok {
+		return fmt.Errorf("cannot redo migration '%v', it is missing from the provider", last)
+	}
+	return m.run(ctx, s, []string{last}, []string{last})
+}
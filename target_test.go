@@ -0,0 +1,255 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMigrate_UpTo(t *testing.T) {
+	tests := []struct {
+		name        string
+		applied     []string
+		target      string
+		wantErr     bool
+		wantApplied []string
+	}{
+		{
+			name:        "up to a pending migration",
+			applied:     []string{"aaa"},
+			target:      "ccc",
+			wantApplied: []string{"aaa", "bbb", "ccc"},
+		},
+		{
+			name:        "up to an applied migration",
+			applied:     []string{"aaa", "bbb"},
+			target:      "aaa",
+			wantApplied: []string{"aaa", "bbb"},
+		},
+		{
+			name:        "up to an unknown migration",
+			applied:     []string{"aaa"},
+			target:      "zzz",
+			wantErr:     true,
+			wantApplied: []string{"aaa"},
+		},
+		{
+			name:        "up to leaves missing migrations alone",
+			applied:     []string{"aaa", "abc"},
+			target:      "bbb",
+			wantApplied: []string{"aaa", "abc", "bbb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockAdapter{applied: tt.applied}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc", "ddd"}}))
+			if err := m.UpTo(context.Background(), tt.target); (err != nil) != tt.wantErr {
+				t.Errorf("UpTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(a.applied, tt.wantApplied) {
+				t.Errorf("UpTo() applied = %v, want %v", a.applied, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestMigrate_DownTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		downErr  error
+		wantErr  bool
+		wantDown []string
+	}{
+		{
+			name:     "down to an applied migration",
+			target:   "aaa",
+			wantDown: []string{"ccc", "bbb"},
+		},
+		{
+			name:   "down to the last applied migration",
+			target: "ccc",
+		},
+		{
+			name:    "down to an unapplied migration",
+			target:  "ddd",
+			wantErr: true,
+		},
+		{
+			name:     "down to with down error",
+			target:   "aaa",
+			downErr:  fmt.Errorf("fail down"),
+			wantErr:  true,
+			wantDown: []string{"ccc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockAdapter{applied: []string{"aaa", "bbb", "ccc"}, downErr: tt.downErr}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc", "ddd"}}))
+			if err := m.DownTo(context.Background(), tt.target); (err != nil) != tt.wantErr {
+				t.Errorf("DownTo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(a.down, tt.wantDown) {
+				t.Errorf("DownTo() down = %v, want %v", a.down, tt.wantDown)
+			}
+		})
+	}
+}
+
+func TestMigrate_Rollback(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       int
+		wantErr     bool
+		wantApplied []string
+	}{
+		{
+			name:        "rollback one",
+			steps:       1,
+			wantApplied: []string{"aaa", "bbb"},
+		},
+		{
+			name:        "rollback all",
+			steps:       3,
+			wantApplied: []string{},
+		},
+		{
+			name:        "rollback zero",
+			steps:       0,
+			wantErr:     true,
+			wantApplied: []string{"aaa", "bbb", "ccc"},
+		},
+		{
+			name:        "rollback too many",
+			steps:       4,
+			wantErr:     true,
+			wantApplied: []string{"aaa", "bbb", "ccc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockAdapter{applied: []string{"aaa", "bbb", "ccc"}}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc"}}))
+			if err := m.Rollback(context.Background(), tt.steps); (err != nil) != tt.wantErr {
+				t.Errorf("Rollback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(a.applied, tt.wantApplied) {
+				t.Errorf("Rollback() applied = %v, want %v", a.applied, tt.wantApplied)
+			}
+		})
+	}
+}
+
+func TestMigrate_Redo(t *testing.T) {
+	tests := []struct {
+		name        string
+		applied     []string
+		wantErr     bool
+		wantCalls   []string
+		wantApplied []string
+	}{
+		{
+			name:        "redo the last applied migration",
+			applied:     []string{"aaa", "bbb"},
+			wantCalls:   []string{"begin", "down bbb", "up bbb", "commit"},
+			wantApplied: []string{"aaa", "bbb"},
+		},
+		{
+			name:        "redo leaves pending migrations alone",
+			applied:     []string{"aaa"},
+			wantCalls:   []string{"begin", "down aaa", "up aaa", "commit"},
+			wantApplied: []string{"aaa"},
+		},
+		{
+			name:        "redo without applied migrations",
+			applied:     []string{},
+			wantErr:     true,
+			wantApplied: []string{},
+		},
+		{
+			name:        "redo a missing migration",
+			applied:     []string{"aaa", "abc"},
+			wantErr:     true,
+			wantApplied: []string{"aaa", "abc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &MockAdapter{applied: tt.applied}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc"}}))
+			if err := m.Redo(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Redo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(a.calls, tt.wantCalls) {
+				t.Errorf("Redo() calls = %v, want %v", a.calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(a.applied, tt.wantApplied) {
+				t.Errorf("Redo() applied = %v, want %v", a.applied, tt.wantApplied)
+			}
+		})
+	}
+}
+
+// MockAppliedListerAdapter lists the applied migrations in the order they were applied, which differs from name order
+type MockAppliedListerAdapter struct {
+	MockAdapter
+	order   []string
+	listErr error
+}
+
+func (m *MockAppliedListerAdapter) ListAppliedContext(ctx context.Context) ([]string, error) {
+	return m.order, m.listErr
+}
+
+func TestMigrate_WithAppliedLister(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(m *Migrate) error
+		listErr  error
+		wantErr  bool
+		wantDown []string
+	}{
+		{
+			name:     "rollback takes down the most recently applied",
+			run:      func(m *Migrate) error { return m.Rollback(context.Background(), 2) },
+			wantDown: []string{"bbb", "ccc"},
+		},
+		{
+			name:     "down to takes down what was applied after",
+			run:      func(m *Migrate) error { return m.DownTo(context.Background(), "ccc") },
+			wantDown: []string{"bbb"},
+		},
+		{
+			name:     "redo takes down the most recently applied",
+			run:      func(m *Migrate) error { return m.Redo(context.Background()) },
+			wantDown: []string{"bbb"},
+		},
+		{
+			name:    "list error",
+			run:     func(m *Migrate) error { return m.Rollback(context.Background(), 1) },
+			listErr: fmt.Errorf("fail list"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// bbb was applied out of order, after ccc
+			a := &MockAppliedListerAdapter{
+				MockAdapter: MockAdapter{applied: []string{"aaa", "bbb", "ccc"}},
+				order:       []string{"aaa", "ccc", "bbb"},
+				listErr:     tt.listErr,
+			}
+			m := New(WithAdapter(a), WithProvider(&MockProvider{names: []string{"aaa", "bbb", "ccc"}}))
+			if err := tt.run(m); (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(a.down, tt.wantDown) {
+				t.Errorf("down = %v, want %v", a.down, tt.wantDown)
+			}
+		})
+	}
+}